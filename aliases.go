@@ -0,0 +1,120 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// aliasPrefix redirects all paths below the prefix
+type aliasPrefix struct {
+	from, to string
+}
+
+// aliases maps old paths to the new paths they are redirected to
+type aliases struct {
+	exact    map[string]string
+	prefixes []aliasPrefix
+	// nested are the prefixes dropped for moving below themselves
+	nested []string
+}
+
+// newAliases splits the map into exact and prefix aliases. Prefixes are
+// ordered longest first so the most specific one matches. A prefix moving
+// below itself, like "/docs/" to "/docs/v2/", would grow the path on every
+// hop and is dropped.
+func newAliases(m map[string]string) *aliases {
+	a := &aliases{exact: make(map[string]string)}
+	for from, to := range m {
+		if strings.HasSuffix(from, "/") && from != "/" {
+			p := aliasPrefix{from: cleanDir(from), to: cleanDir(to)}
+			if strings.HasPrefix(p.to, p.from) {
+				a.nested = append(a.nested, p.from)
+				continue
+			}
+			a.prefixes = append(a.prefixes, p)
+			continue
+		}
+		a.exact[path.Clean("/"+from)] = to
+	}
+	sort.Slice(a.prefixes, func(i, j int) bool {
+		return len(a.prefixes[i].from) > len(a.prefixes[j].from)
+	})
+	sort.Strings(a.nested)
+	return a
+}
+
+// cleanDir cleans the directory path keeping its trailing '/'
+func cleanDir(dir string) string {
+	dir = path.Clean("/" + dir)
+	if dir != "/" {
+		dir += "/"
+	}
+	return dir
+}
+
+// lookup returns the target of the alias matching the path. Paths of
+// directories ending in '/' only match prefix aliases.
+func (a *aliases) lookup(upath string) (string, bool) {
+	if to, ok := a.exact[upath]; ok {
+		return to, true
+	}
+	for _, p := range a.prefixes {
+		if upath+"/" == p.from {
+			return p.to, true
+		}
+		if strings.HasPrefix(upath, p.from) {
+			return p.to + upath[len(p.from):], true
+		}
+	}
+	return "", false
+}
+
+// target follows aliases of aliases to the final path, so clients get a
+// single redirect. Paths leading into a cycle have no target. A chain
+// without a cycle uses every alias at most once, longer ones are cut off
+// like cycles.
+func (a *aliases) target(upath string) (string, bool) {
+	to, ok := a.lookup(upath)
+	if !ok {
+		return "", false
+	}
+	seen := map[string]bool{upath: true}
+	for hops := len(a.exact) + len(a.prefixes); hops > 0; hops-- {
+		next := to
+		if strings.HasPrefix(next, "/") && !strings.HasSuffix(next, "/") {
+			next = path.Clean(next)
+		}
+		if seen[next] {
+			return "", false
+		}
+		seen[next] = true
+		hop, ok := a.lookup(next)
+		if !ok {
+			return to, true
+		}
+		to = hop
+	}
+	return "", false
+}
+
+// cycles returns the aliased paths leading into a cycle
+func (a *aliases) cycles() []string {
+	var looping []string
+	for from := range a.exact {
+		if _, ok := a.target(from); !ok {
+			looping = append(looping, from)
+		}
+	}
+	for _, p := range a.prefixes {
+		if _, ok := a.target(strings.TrimSuffix(p.from, "/")); !ok {
+			looping = append(looping, p.from)
+		}
+	}
+	sort.Strings(looping)
+	return looping
+}
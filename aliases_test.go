@@ -0,0 +1,79 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAliases(t *testing.T) {
+	root := MapFS(map[string]string{"manual/a.html": "a", "new.html": "new"})
+	h := New(root, testNotFound, WithAliases(map[string]string{
+		"/old.html":   "/older.html",
+		"/older.html": "/new.html",
+		"/docs/":      "/manual/",
+	}))
+
+	tests := []struct {
+		target, location string
+	}{
+		{"/old.html", "/new.html"},
+		{"/older.html?q=1", "/new.html?q=1"},
+		{"/docs/a.html", "/manual/a.html"},
+		{"/docs", "/manual/"},
+		{"/docs/", "/manual/"},
+	}
+	for _, tt := range tests {
+		w := serve(h, "GET", tt.target)
+		expect(t, w, 301, "")
+		if got := w.Header().Get("Location"); got != tt.location {
+			t.Errorf("%s: Location = %q, want %q", tt.target, got, tt.location)
+		}
+	}
+	expect(t, serve(h, "GET", "/manual/a.html"), 200, "a")
+}
+
+func TestAliasesCycle(t *testing.T) {
+	table := map[string]string{"/a": "/b", "/b": "/a"}
+	h := New(MapFS(map[string]string{"a": "A"}), testNotFound, WithAliases(table))
+	expect(t, serve(h, "GET", "/a"), 200, "A")
+	expect(t, serve(h, "GET", "/b"), 404, notFoundBody)
+
+	_, err := NewStrict(MapFS(nil), testNotFound, WithAliases(table))
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("NewStrict error = %v, want a cycle", err)
+	}
+}
+
+func TestAliasesNested(t *testing.T) {
+	tables := []map[string]string{
+		{"/docs/": "/docs/v2/"},
+		// Mutual prefixes growing the path on every hop
+		{"/a/": "/b/", "/b/": "/a/x/"},
+	}
+	for _, table := range tables {
+		done := make(chan error, 1)
+		go func() {
+			h := New(MapFS(map[string]string{"docs/v2/a.html": "v2"}), testNotFound, WithAliases(table))
+			serve(h, "GET", "/docs/a.html")
+			serve(h, "GET", "/a/q")
+			_, err := NewStrict(MapFS(nil), testNotFound, WithAliases(table))
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("%v: NewStrict accepted growing aliases", table)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%v: aliases are followed forever", table)
+		}
+	}
+
+	h := New(MapFS(map[string]string{"docs/a.html": "a"}), testNotFound, WithAliases(map[string]string{"/docs/": "/docs/v2/"}))
+	expect(t, serve(h, "GET", "/docs/a.html"), 200, "a")
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// cacheBustKey is the request context key marking requests bypassing
+// the caches
+type cacheBustKey struct{}
+
+// cacheBust marks the request to bypass the caches when it carries the
+// cache busting parameter, and makes the response not stored by clients.
+func (fs *FileSystemWith404) cacheBust(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+	v := r.URL.Query().Get(fs.cacheBustParam)
+	if bust, err := strconv.ParseBool(v); err != nil || !bust {
+		return w, r
+	}
+	w = onWriteHeader(w, func(h http.Header, _ int) {
+		h.Set("Cache-Control", "no-store")
+	})
+	return w, r.WithContext(context.WithValue(r.Context(), cacheBustKey{}, true))
+}
+
+// cacheBusted reports if the request bypasses the caches
+func cacheBusted(r *http.Request) bool {
+	busted, _ := r.Context().Value(cacheBustKey{}).(bool)
+	return busted
+}
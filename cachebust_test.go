@@ -0,0 +1,57 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCacheBustParam(t *testing.T) {
+	// The content changes without changing ModTime or size, so only a
+	// fresh read notices it
+	root := &changingFS{files: map[string]string{"assets/a.txt": "aaaa"}}
+	fs := New(root, testNotFound, WithETag(true), WithCacheBustParam("nocache"), WithImmutablePrefix("/assets/", false))
+	tag := serve(fs, http.MethodGet, "/assets/a.txt").Header().Get("ETag")
+	root.set("assets/a.txt", "bbbb")
+
+	w := serve(fs, http.MethodGet, "/assets/a.txt")
+	expect(t, w, http.StatusOK, "bbbb")
+	if got := w.Header().Get("ETag"); got != tag {
+		t.Errorf("ETag %s not taken from the cache %s", got, tag)
+	}
+	if got := w.Header().Get("Cache-Control"); got != immutableCacheControl {
+		t.Errorf("Cache-Control %q without the parameter", got)
+	}
+
+	for _, target := range []string{"/assets/a.txt?nocache=1", "/assets/a.txt?v=2&nocache=true"} {
+		w = serve(fs, http.MethodGet, target)
+		expect(t, w, http.StatusOK, "bbbb")
+		if got := w.Header().Get("ETag"); got == tag || got == "" {
+			t.Errorf("%s: ETag %q, want a fresh one", target, got)
+		}
+		if got := w.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("%s: Cache-Control %q, want no-store", target, got)
+		}
+	}
+
+	// Other values do not bust the caches
+	for _, target := range []string{"/assets/a.txt?nocache=0", "/assets/a.txt?nocache=", "/assets/a.txt?other=1"} {
+		w = serve(fs, http.MethodGet, target)
+		if got := w.Header().Get("ETag"); got != tag {
+			t.Errorf("%s: ETag %s not taken from the cache %s", target, got, tag)
+		}
+		if got := w.Header().Get("Cache-Control"); got == "no-store" {
+			t.Errorf("%s: Cache-Control no-store", target)
+		}
+	}
+
+	// Error responses are not stored either
+	w = serve(fs, http.MethodGet, "/missing?nocache=1")
+	expect(t, w, http.StatusNotFound, notFoundBody)
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("404 Cache-Control %q, want no-store", got)
+	}
+}
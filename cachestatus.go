@@ -0,0 +1,32 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+)
+
+// Values of the WithCacheStatusHeader header
+const (
+	cacheHit    = "HIT"
+	cacheMiss   = "MISS"
+	cacheBypass = "BYPASS"
+)
+
+// cacheStatus returns if the ETag and transform caches had the content.
+// Content served without consulting them, like with WithETag disabled or
+// busted caches, bypassed them.
+func (t *serverTiming) cacheStatus(r *http.Request) string {
+	if t == nil || cacheBusted(r) {
+		return cacheBypass
+	}
+	if t.descs["cache"] == "hit" || t.descs["transform"] == "hit" {
+		return cacheHit
+	}
+	if _, transformed := t.metrics["transform"]; transformed || t.descs["cache"] == "miss" {
+		return cacheMiss
+	}
+	return cacheBypass
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func cacheStatusOf(h http.Handler, target string) string {
+	return serve(h, http.MethodGet, target).Header().Get("X-Cache")
+}
+
+func TestCacheStatusHeader(t *testing.T) {
+	root := &changingFS{files: map[string]string{"a.txt": "aaaa"}}
+	fs := New(root, testNotFound, WithETag(true), WithCacheStatusHeader("X-Cache"), WithCacheBustParam("nocache"))
+
+	// Cold, then warm
+	if got := cacheStatusOf(fs, "/a.txt"); got != cacheMiss {
+		t.Errorf("cold request X-Cache %q, want MISS", got)
+	}
+	if got := cacheStatusOf(fs, "/a.txt"); got != cacheHit {
+		t.Errorf("warm request X-Cache %q, want HIT", got)
+	}
+	if got := cacheStatusOf(fs, "/a.txt?nocache=1"); got != cacheBypass {
+		t.Errorf("busted request X-Cache %q, want BYPASS", got)
+	}
+
+	// A changed file is cold again
+	root.set("a.txt", "aaaaa")
+	if got := cacheStatusOf(fs, "/a.txt"); got != cacheMiss {
+		t.Errorf("changed file X-Cache %q, want MISS", got)
+	}
+	if got := cacheStatusOf(fs, "/missing"); got != "" {
+		t.Errorf("404 X-Cache %q", got)
+	}
+
+	// Without the ETag cache files bypass it
+	fs = New(root, testNotFound, WithCacheStatusHeader("X-Cache"))
+	if got := cacheStatusOf(fs, "/a.txt"); got != cacheBypass {
+		t.Errorf("X-Cache %q without ETags, want BYPASS", got)
+	}
+
+	// Transformed content is cached too
+	fs = New(root, testNotFound, WithCacheStatusHeader("X-Cache"), WithReadSeekerTransform(func(name string, rs io.ReadSeeker) (io.ReadSeeker, error) {
+		data, err := io.ReadAll(rs)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(bytes.ToUpper(data)), nil
+	}))
+	for _, want := range []string{cacheMiss, cacheHit} {
+		w := serve(fs, http.MethodGet, "/a.txt")
+		expect(t, w, http.StatusOK, "AAAAA")
+		if got := w.Header().Get("X-Cache"); got != want {
+			t.Errorf("transformed X-Cache %q, want %s", got, want)
+		}
+	}
+
+	// Off by default
+	if got := cacheStatusOf(New(root, testNotFound, WithETag(true)), "/a.txt"); got != "" {
+		t.Errorf("X-Cache %q without the option", got)
+	}
+}
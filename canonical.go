@@ -0,0 +1,97 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// CanonicalURLPolicy selects the one URL shape pages are served at, other
+// shapes of the same page are redirected to it
+type CanonicalURLPolicy int
+
+// Policies of WithCanonicalURLPolicy
+const (
+	// PolicyNone leaves the URL shape to the individual options
+	PolicyNone CanonicalURLPolicy = iota
+	// PolicyTrailingSlash serves directories at "/docs/" and files at
+	// "/page.html". "/docs", "/docs/index.html" and "/page.html/" are
+	// redirected.
+	PolicyTrailingSlash
+	// PolicyNoTrailingSlash serves directories having an index page at
+	// "/docs" and files at "/page.html". "/docs/", "/docs/index.html" and
+	// "/page.html/" are redirected. Directories without an index page keep
+	// the trailing slash their listing needs.
+	PolicyNoTrailingSlash
+	// PolicyExtensionless serves directories at "/docs/" and HTML pages
+	// without their extension at "/page", from the file "/page.html".
+	// "/docs", "/docs/index.html" and "/page.html" are redirected.
+	PolicyExtensionless
+)
+
+// htmlExt is the extension PolicyExtensionless drops from page URLs
+const htmlExt = ".html"
+
+// canonicalRedirect returns the redirect of the path to its canonical
+// shape, if it has another one. Paths naming nothing are left alone.
+func (fs *FileSystemWith404) canonicalRedirect(r *http.Request, urlPath string, st *serverTiming) (resolution, bool) {
+	ctx := r.Context()
+	upath := path.Clean(urlPath)
+	if upath == "/" {
+		return resolution{}, false
+	}
+	slash := strings.HasSuffix(urlPath, "/")
+	base := path.Base(upath)
+
+	// Index pages are only linked by their directory
+	extensionless := fs.canonicalPolicy == PolicyExtensionless && !slash
+	if fs.isIndexPage(ctx, urlPath, st) || (extensionless && fs.isIndexPage(ctx, urlPath+htmlExt, st)) {
+		dir := path.Dir(upath)
+		if fs.canonicalPolicy == PolicyNoTrailingSlash && dir != "/" {
+			return canonicalTo(r, "../"+path.Base(dir)), true
+		}
+		return canonicalTo(r, "./"), true
+	}
+
+	f, d, err := fs.open(ctx, upath, st)
+	if err != nil {
+		return resolution{}, false
+	}
+	f.Close()
+
+	if !d.IsDir() && fs.canonicalPolicy == PolicyExtensionless && strings.HasSuffix(base, htmlExt) {
+		page := strings.TrimSuffix(upath, htmlExt)
+		// The file named like the page would shadow it
+		if f, _, err := fs.open(ctx, page, st); err == nil {
+			f.Close()
+		} else {
+			base = path.Base(page)
+			if !slash {
+				return canonicalTo(r, base), true
+			}
+		}
+	}
+
+	switch {
+	case slash && !d.IsDir():
+		// Files are never directories
+		return canonicalTo(r, "../"+base), true
+	case slash && fs.canonicalPolicy == PolicyNoTrailingSlash:
+		if _, idx, _, err := fs.openIndex(ctx, upath, st); err == nil {
+			idx.Close()
+			return canonicalTo(r, "../"+base), true
+		}
+	}
+	return resolution{}, false
+}
+
+// canonicalTo returns the local permanent redirect to the location
+func canonicalTo(r *http.Request, location string) resolution {
+	res := redirectTo(r, location, http.StatusMovedPermanently)
+	res.local = true
+	return res
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedirectIndexToDir(t *testing.T) {
+	files := map[string]string{"index.html": "root", "sub/index.html": "sub", "sub/a.html": "a"}
+	fs := New(MapFS(files), testNotFound, WithRedirectIndexToDir(true))
+	for target, location := range map[string]string{
+		"/index.html":         "./",
+		"/sub/index.html":     "./",
+		"/sub/index.html?x=1": "./?x=1",
+	} {
+		w := serve(fs, http.MethodGet, target)
+		expect(t, w, http.StatusMovedPermanently, "")
+		if got := w.Header().Get("Location"); got != location {
+			t.Errorf("%s: Location = %q, want %q", target, got, location)
+		}
+	}
+	expect(t, serve(fs, http.MethodGet, "/"), http.StatusOK, "root")
+	expect(t, serve(fs, http.MethodGet, "/sub/"), http.StatusOK, "sub")
+	expect(t, serve(fs, http.MethodGet, "/sub/a.html"), http.StatusOK, "a")
+
+	fs = New(MapFS(files), testNotFound)
+	expect(t, serve(fs, http.MethodGet, "/sub/index.html"), http.StatusOK, "sub")
+}
+
+// follow serves the target following local redirects. It returns the
+// final URL and response, failing the test on a loop.
+func follow(t *testing.T, h http.Handler, target string) (*url.URL, *httptest.ResponseRecorder) {
+	t.Helper()
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for {
+		if seen[u.String()] {
+			t.Errorf("%s: redirect loop at %s", target, u)
+			return u, nil
+		}
+		seen[u.String()] = true
+		w := serve(h, http.MethodGet, u.String())
+		if w.Code != http.StatusMovedPermanently {
+			return u, w
+		}
+		loc, err := url.Parse(w.Header().Get("Location"))
+		if err != nil {
+			t.Fatalf("%s: Location %q: %v", u, w.Header().Get("Location"), err)
+		}
+		if loc.IsAbs() || strings.HasPrefix(loc.Path, "/") {
+			t.Errorf("%s: Location %q is not relative", u, loc)
+		}
+		u = u.ResolveReference(loc)
+	}
+}
+
+func TestCanonicalURLPolicyLoops(t *testing.T) {
+	files := MapFS(map[string]string{
+		"index.html":       "home",
+		"docs/index.html":  "docs index",
+		"docs/guide.html":  "docs guide",
+		"docs/notes.txt":   "docs notes",
+		"a/b/index.html":   "nested index",
+		"page.html":        "page",
+		"about.html":       "about page",
+		"about/index.html": "about index",
+		"shadow.html":      "shadow page",
+		"shadow":           "shadow file",
+		"list/a.txt":       "listed",
+		"data.html/x.txt":  "dir named like a page",
+	})
+	stems := []string{"", "/docs", "/docs/guide", "/docs/notes.txt", "/a/b", "/a", "/page", "/about", "/shadow", "/list", "/data", "/missing"}
+	suffixes := []string{"", "/", "/index.html", "/index", ".html", ".html/", "/."}
+	policies := map[CanonicalURLPolicy]string{
+		PolicyNone:            "none",
+		PolicyTrailingSlash:   "trailing slash",
+		PolicyNoTrailingSlash: "no trailing slash",
+		PolicyExtensionless:   "extensionless",
+	}
+	// Pages whose extensionless URL names another file or directory
+	shadowed := map[string]bool{"shadow page": true, "about page": true}
+
+	for policy, name := range policies {
+		fs := New(files, testNotFound, WithCanonicalURLPolicy(policy), WithDirectoryListing(true))
+		// The final URL every body is served at
+		canonical := map[string]string{}
+		for _, stem := range stems {
+			for _, suffix := range suffixes {
+				for _, query := range []string{"", "?v=1"} {
+					target := stem + suffix + query
+					if !strings.HasPrefix(target, "/") {
+						target = "/" + target
+					}
+					final, w := follow(t, fs, target)
+					if w == nil {
+						continue
+					}
+					if final.RawQuery != strings.TrimPrefix(query, "?") {
+						t.Errorf("%s: %s: query lost, ends at %s", name, target, final)
+					}
+					if w.Code != http.StatusOK {
+						continue
+					}
+					if policy == PolicyNone {
+						continue
+					}
+					// Indexes served without the slash carry a <base>
+					body := baseTag.ReplaceAllString(w.Body.String(), "")
+					if prev, ok := canonical[body]; ok && prev != final.Path {
+						t.Errorf("%s: %s ends at %s, the same content is also at %s", name, target, final.Path, prev)
+					}
+					canonical[body] = final.Path
+					if strings.HasSuffix(final.Path, "/index.html") {
+						t.Errorf("%s: %s ends at the index page %s", name, target, final.Path)
+					}
+				}
+			}
+		}
+
+		for body, want := range map[CanonicalURLPolicy]map[string]string{
+			PolicyTrailingSlash:   {"docs index": "/docs/", "nested index": "/a/b/", "page": "/page.html"},
+			PolicyNoTrailingSlash: {"docs index": "/docs", "nested index": "/a/b", "page": "/page.html"},
+			PolicyExtensionless:   {"docs index": "/docs/", "docs guide": "/docs/guide", "page": "/page"},
+		}[policy] {
+			if canonical[body] != want {
+				t.Errorf("%s: %q served at %s, want %s", name, body, canonical[body], want)
+			}
+		}
+
+		// Check the shape of the canonical URLs
+		for body, final := range canonical {
+			switch {
+			case policy == PolicyTrailingSlash && strings.Contains(body, "index") && !strings.HasSuffix(final, "/"):
+				t.Errorf("%s: directory %s without trailing slash", name, final)
+			case policy == PolicyNoTrailingSlash && strings.Contains(body, "index") && final != "/" && strings.HasSuffix(final, "/"):
+				t.Errorf("%s: directory %s with trailing slash", name, final)
+			case policy == PolicyExtensionless && strings.HasSuffix(final, htmlExt) && !shadowed[body]:
+				t.Errorf("%s: page %s with extension", name, final)
+			}
+		}
+	}
+}
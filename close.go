@@ -0,0 +1,29 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Close releases everything cached by the handler. Requests served after
+// Close get a Service Unavailable response, so a discarded handler left
+// mounted on a router does not touch its file system anymore. It is safe
+// to call more than once.
+func (fs *FileSystemWith404) Close() error {
+	atomic.StoreInt32(&fs.closed, 1)
+	fs.InvalidateAll()
+	return nil
+}
+
+// unavailable answers the request when the handler is closed
+func (fs *FileSystemWith404) unavailable(w http.ResponseWriter, r *http.Request) bool {
+	if atomic.LoadInt32(&fs.closed) == 0 {
+		return false
+	}
+	plainError(w, http.StatusServiceUnavailable)
+	return true
+}
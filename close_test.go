@@ -0,0 +1,49 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestClose(t *testing.T) {
+	fs := New(MapFS(map[string]string{"a.txt": "a"}), testNotFound, WithETag(true))
+	expect(t, serve(fs, http.MethodGet, "/a.txt"), http.StatusOK, "a")
+	for i := 0; i < 2; i++ {
+		if err := fs.Close(); err != nil {
+			t.Fatalf("Close %d: %v", i, err)
+		}
+	}
+	expect(t, serve(fs, http.MethodGet, "/a.txt"), http.StatusServiceUnavailable, "")
+	if len(fs.etags.entries) != 0 {
+		t.Errorf("%d ETags kept after Close", len(fs.etags.entries))
+	}
+}
+
+func TestCloseNoGoroutineLeak(t *testing.T) {
+	files := map[string]string{"index.html": "home", "a.txt": "a"}
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		fs := New(MapFS(files), testNotFound, WithETag(true), WithGzip(true),
+			WithNotFoundDelay(time.Millisecond), WithMaxConcurrentServes(2, QueueServes))
+		if err := fs.PrecomputeETags(); err != nil {
+			t.Fatal(err)
+		}
+		serve(fs, http.MethodGet, "/", "Accept-Encoding", "gzip")
+		serve(fs, http.MethodGet, "/missing")
+		fs.Close()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines before, %d after closing", before, after)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// nonceKey is the request context key of the CSP nonce
+type nonceKey struct{}
+
+// newNonce generates a random base64 encoded CSP nonce
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// withNonce returns the request carrying the CSP nonce in its context
+func withNonce(r *http.Request, nonce string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), nonceKey{}, nonce))
+}
+
+// CSPNonce returns the Content-Security-Policy nonce generated for the
+// request when WithCSPNonce is enabled. It allows not found handlers to
+// render inline scripts and styles permitted by the policy. An empty
+// string is returned otherwise.
+func CSPNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(nonceKey{}).(string)
+	return nonce
+}
+
+// setCSP sets the enforced and the report-only policy headers. With
+// WithCSPNonce both carry the same nonce, which the returned request holds.
+func (fs *FileSystemWith404) setCSP(w http.ResponseWriter, r *http.Request) (*http.Request, error) {
+	policy, reportOnly := fs.csp, fs.cspReportOnly
+	if fs.cspNonce {
+		nonce, err := newNonce()
+		if err != nil {
+			return r, err
+		}
+		r = withNonce(r, nonce)
+		if policy != "" {
+			policy = policyWithNonce(policy, nonce)
+		}
+		if reportOnly != "" {
+			reportOnly = policyWithNonce(reportOnly, nonce)
+		}
+	}
+	h := w.Header()
+	if policy != "" {
+		h.Set("Content-Security-Policy", policy)
+	}
+	if reportOnly != "" {
+		h.Set("Content-Security-Policy-Report-Only", reportOnly)
+	}
+	return r, nil
+}
+
+// policyWithNonce adds the nonce source to the script-src and style-src
+// directives of the policy. When either is missing the nonce is added to
+// default-src as well, which the missing one falls back to.
+func policyWithNonce(policy, nonce string) string {
+	source := "'nonce-" + nonce + "'"
+	directives := strings.Split(policy, ";")
+
+	script, style := false, false
+	for i, d := range directives {
+		switch directiveName(d) {
+		case "script-src":
+			script = true
+		case "style-src":
+			style = true
+		default:
+			continue
+		}
+		directives[i] = strings.TrimRight(d, " ") + " " + source
+	}
+	if !script || !style {
+		for i, d := range directives {
+			if directiveName(d) == "default-src" {
+				directives[i] = strings.TrimRight(d, " ") + " " + source
+			}
+		}
+	}
+	return strings.Join(directives, ";")
+}
+
+// directiveName returns the lower cased name of the CSP directive
+func directiveName(directive string) string {
+	fields := strings.Fields(directive)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
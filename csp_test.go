@@ -0,0 +1,113 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+// nonceSource extracts the nonce of a policy
+var nonceSource = regexp.MustCompile(`'nonce-([^']+)'`)
+
+// nonceAttr extracts the nonces of an HTML document
+var nonceAttr = regexp.MustCompile(`<(?:script|style) nonce="([^"]+)"`)
+
+func TestPolicyWithNonce(t *testing.T) {
+	for policy, want := range map[string]string{
+		"default-src 'self'":                     "default-src 'self' 'nonce-n'",
+		"default-src 'self'; script-src 'self' ": "default-src 'self' 'nonce-n'; script-src 'self' 'nonce-n'",
+		"style-src 'self'; default-src 'none'":   "style-src 'self' 'nonce-n'; default-src 'none' 'nonce-n'",
+		"script-src 'self'; STYLE-SRC 'self'":    "script-src 'self' 'nonce-n'; STYLE-SRC 'self' 'nonce-n'",
+		"img-src *":                              "img-src *",
+		// The default is left alone when both directives are present
+		"default-src 'self'; script-src 'self'; style-src 'self'": "default-src 'self'; script-src 'self' 'nonce-n'; style-src 'self' 'nonce-n'",
+	} {
+		if got := policyWithNonce(policy, "n"); got != want {
+			t.Errorf("policyWithNonce(%q) = %q, want %q", policy, got, want)
+		}
+	}
+}
+
+func TestCSPNonce(t *testing.T) {
+	page := "<html><head><style>p{}</style></head><body><script>run()</script></body></html>"
+	var handlerNonce string
+	fs := New(MapFS(map[string]string{"index.html": page}), func(w http.ResponseWriter, r *http.Request) {
+		handlerNonce = CSPNonce(r)
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, notFoundBody)
+	}, WithCSP("default-src 'self'; script-src 'self'"), WithCSPNonce(true))
+
+	w := serve(fs, http.MethodGet, "/")
+	expect(t, w, http.StatusOK, "")
+	m := nonceSource.FindStringSubmatch(w.Header().Get("Content-Security-Policy"))
+	if m == nil {
+		t.Fatalf("policy %q has no nonce", w.Header().Get("Content-Security-Policy"))
+	}
+	attrs := nonceAttr.FindAllStringSubmatch(w.Body.String(), -1)
+	if len(attrs) != 2 {
+		t.Fatalf("page %q, want two nonce attributes", w.Body.String())
+	}
+	for _, attr := range attrs {
+		if attr[1] != m[1] {
+			t.Errorf("injected nonce %s, header nonce %s", attr[1], m[1])
+		}
+	}
+
+	other := nonceSource.FindStringSubmatch(serve(fs, http.MethodGet, "/").Header().Get("Content-Security-Policy"))
+	if other == nil || other[1] == m[1] {
+		t.Errorf("nonce %v reused across requests", other)
+	}
+
+	w = serve(fs, http.MethodGet, "/missing")
+	m = nonceSource.FindStringSubmatch(w.Header().Get("Content-Security-Policy"))
+	if m == nil || handlerNonce != m[1] {
+		t.Errorf("notFound handler nonce %q, header %v", handlerNonce, m)
+	}
+}
+
+func TestCSPReportOnly(t *testing.T) {
+	page := "<html><head></head><body><script>run()</script></body></html>"
+	files := MapFS(map[string]string{"index.html": page, "a.txt": "a"})
+
+	// Next to the enforced policy, sharing its nonce
+	fs := New(files, testNotFound, WithCSP("script-src 'self'"), WithCSPReportOnly("default-src 'none'; script-src 'strict-dynamic'"), WithCSPNonce(true))
+	w := serve(fs, http.MethodGet, "/")
+	expect(t, w, http.StatusOK, "")
+	enforced := nonceSource.FindStringSubmatch(w.Header().Get("Content-Security-Policy"))
+	reportOnly := nonceSource.FindStringSubmatch(w.Header().Get("Content-Security-Policy-Report-Only"))
+	attr := nonceAttr.FindStringSubmatch(w.Body.String())
+	if enforced == nil || reportOnly == nil || attr == nil {
+		t.Fatalf("nonce missing, policy %v, report only %v, page %q", enforced, reportOnly, w.Body.String())
+	}
+	if enforced[1] != reportOnly[1] || attr[1] != reportOnly[1] {
+		t.Errorf("nonces differ, policy %s, report only %s, page %s", enforced[1], reportOnly[1], attr[1])
+	}
+	if got, want := w.Header().Get("Content-Security-Policy-Report-Only"), "default-src 'none' 'nonce-"+attr[1]+"'; script-src 'strict-dynamic' 'nonce-"+attr[1]+"'"; got != want {
+		t.Errorf("report only policy %q, want %q", got, want)
+	}
+
+	// On its own, without enforcing anything
+	fs = New(files, testNotFound, WithCSPReportOnly("default-src 'self'"), WithCSPNonce(true))
+	w = serve(fs, http.MethodGet, "/")
+	if got := w.Header().Get("Content-Security-Policy"); got != "" {
+		t.Errorf("enforced policy %q set by the report only option", got)
+	}
+	reportOnly = nonceSource.FindStringSubmatch(w.Header().Get("Content-Security-Policy-Report-Only"))
+	attr = nonceAttr.FindStringSubmatch(w.Body.String())
+	if reportOnly == nil || attr == nil || reportOnly[1] != attr[1] {
+		t.Errorf("report only nonce %v, page nonce %v", reportOnly, attr)
+	}
+
+	// Without nonces the policy is sent as is, also for other types
+	fs = New(files, testNotFound, WithCSPReportOnly("default-src 'self'"))
+	for _, target := range []string{"/", "/a.txt"} {
+		if got := serve(fs, http.MethodGet, target).Header().Get("Content-Security-Policy-Report-Only"); got != "default-src 'self'" {
+			t.Errorf("%s: report only policy %q", target, got)
+		}
+	}
+}
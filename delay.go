@@ -0,0 +1,57 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// jitterRand draws the jitter of the not found delays. It has its own
+// seed as the global source of math/rand is seeded the same on every
+// start before Go 1.20, which makes the delays predictable.
+var jitterRand = &lockedRand{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// lockedRand is a random source safe for concurrent use
+type lockedRand struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// int63n returns a random number in [0, n)
+func (r *lockedRand) int63n(n int64) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Int63n(n)
+}
+
+// notFoundWait returns the delay of the next not found response
+func (fs *FileSystemWith404) notFoundWait() time.Duration {
+	d := fs.notFoundDelay
+	if fs.notFoundJitter > 0 {
+		d += time.Duration(jitterRand.int63n(int64(fs.notFoundJitter) + 1))
+	}
+	return d
+}
+
+// delayNotFound waits before a not found response and reports if the
+// response should still be written. Each request waits on its own timer,
+// holding no lock, and stops waiting as soon as the client is gone.
+func (fs *FileSystemWith404) delayNotFound(ctx context.Context) bool {
+	d := fs.notFoundWait()
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
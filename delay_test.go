@@ -0,0 +1,112 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const notFoundDelay = 200 * time.Millisecond
+
+func TestNotFoundDelay(t *testing.T) {
+	fs := New(MapFS(map[string]string{"a.txt": "a"}), testNotFound, WithNotFoundDelay(notFoundDelay))
+
+	start := time.Now()
+	expect(t, serve(fs, http.MethodGet, "/missing"), http.StatusNotFound, notFoundBody)
+	if took := time.Since(start); took < notFoundDelay {
+		t.Errorf("404 took %v, want at least %v", took, notFoundDelay)
+	}
+
+	// Concurrent requests wait side by side without blocking files
+	var wg sync.WaitGroup
+	start = time.Now()
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(fs, http.MethodGet, "/missing")
+		}()
+	}
+	time.Sleep(notFoundDelay / 4)
+	fileStart := time.Now()
+	expect(t, serve(fs, http.MethodGet, "/a.txt"), http.StatusOK, "a")
+	if took := time.Since(fileStart); took > notFoundDelay/2 {
+		t.Errorf("file took %v while 404s were delayed", took)
+	}
+	wg.Wait()
+	if took := time.Since(start); took > 3*notFoundDelay {
+		t.Errorf("10 concurrent 404s took %v, want about %v", took, notFoundDelay)
+	}
+}
+
+func TestNotFoundDelayCancel(t *testing.T) {
+	called := false
+	fs := New(MapFS(nil), func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		testNotFound(w, r)
+	}, WithNotFoundDelay(10*time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	r := httptest.NewRequest(http.MethodGet, "/missing", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	fs.ServeHTTP(w, r)
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("canceled 404 took %v", took)
+	}
+	if called || w.Body.Len() > 0 {
+		t.Errorf("response written after the client was gone")
+	}
+}
+
+func TestNotFoundJitter(t *testing.T) {
+	fs := New(MapFS(nil), testNotFound, WithNotFoundDelay(time.Second), WithNotFoundJitter(time.Second))
+	varied := false
+	first := fs.notFoundWait()
+	for i := 0; i < 100; i++ {
+		d := fs.notFoundWait()
+		if d < time.Second || d > 2*time.Second {
+			t.Fatalf("delay %v outside of 1s to 2s", d)
+		}
+		varied = varied || d != first
+	}
+	if !varied {
+		t.Errorf("jitter never varied the delay")
+	}
+}
+
+func TestNotFoundJitterSeeded(t *testing.T) {
+	fs := New(MapFS(nil), testNotFound, WithNotFoundJitter(time.Hour))
+
+	// By Go 1.19, or with GODEBUG=randautoseed=0, the global source of
+	// math/rand draws the same delays on every start as a source seeded
+	// with 1 does
+	global := rand.New(rand.NewSource(1))
+	same := true
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		d := fs.notFoundWait()
+		same = same && int64(d) == global.Int63n(int64(time.Hour)+1)
+
+		// Concurrent draws are safe
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fs.notFoundWait()
+		}()
+	}
+	wg.Wait()
+	if same {
+		t.Errorf("jitter follows the sequence of the default seed")
+	}
+}
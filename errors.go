@@ -0,0 +1,75 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+)
+
+// fail responds with the error status. The error page configured for the
+// status is served when it exists. Otherwise 404 Not Found is handed to
+// the notFound handler and other statuses get a plain text message.
+func (fs *FileSystemWith404) fail(w http.ResponseWriter, r *http.Request, code int, notFound http.HandlerFunc) {
+	if code == http.StatusNotFound && !fs.delayNotFound(r.Context()) {
+		return
+	}
+	if page, ok := fs.errorPages[code]; ok && fs.serveErrorPage(w, r, code, page) {
+		return
+	}
+	if code == http.StatusNotFound && notFound != nil {
+		notFound(w, r)
+		return
+	}
+	plainError(w, code)
+}
+
+// plainError responds with the status text as plain text. The length is
+// always set so the connection can be reused by HTTP/1.0 clients.
+func plainError(w http.ResponseWriter, code int) {
+	msg := http.StatusText(code) + "\n"
+	h := w.Header()
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Length", strconv.Itoa(len(msg)))
+	w.WriteHeader(code)
+	io.WriteString(w, msg)
+}
+
+// serveErrorPage writes the page from the root with the status and
+// reports if it could be served. The page bypasses the hidden filters.
+func (fs *FileSystemWith404) serveErrorPage(w http.ResponseWriter, r *http.Request, code int, page string) bool {
+	f, d, err := fs.open(r.Context(), page, nil)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if d.IsDir() {
+		return false
+	}
+
+	h := w.Header()
+	ctype := mime.TypeByExtension(path.Ext(page))
+	if ctype == "" {
+		head, err := peek(f, sniffLen)
+		if err != nil {
+			return false
+		}
+		ctype = http.DetectContentType(head)
+	}
+	h.Set("Content-Type", ctype)
+	h.Set("Content-Length", strconv.FormatInt(d.Size(), 10))
+	// Error responses must not be cached as the resource
+	h.Del("ETag")
+	h.Del("Last-Modified")
+	w.WriteHeader(code)
+	if r.Method != http.MethodHead {
+		io.CopyN(w, f, d.Size())
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorPages(t *testing.T) {
+	files := map[string]string{
+		".errors/404.html": "<h1>not found</h1>",
+		".errors/403.html": "<h1>forbidden</h1>",
+		"errors/500":       "<!DOCTYPE html><h1>error</h1>",
+		"private/a.txt":    "private",
+		"locked/a.txt":     "locked",
+		"a.txt":            "a",
+	}
+	fetcher := errorFetcher{
+		files: FileSystemFetcher(MapFS(files)),
+		errs:  map[string]error{"/broken/index.html": errors.New("storage unreachable")},
+	}
+	fs := NewFetcher(fetcher, testNotFound, WithErrorPages(map[int]string{
+		http.StatusNotFound:            ".errors/404.html",
+		http.StatusForbidden:           "/.errors/403.html",
+		http.StatusInternalServerError: "/errors/500",
+		http.StatusServiceUnavailable:  "/errors/missing.html",
+	}), WithServeGuard(func(r *http.Request, info os.FileInfo) (bool, int) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/private/"):
+			return false, 0
+		case strings.HasPrefix(r.URL.Path, "/locked/"):
+			return false, http.StatusUnauthorized
+		}
+		return true, 0
+	}))
+
+	for _, c := range []struct {
+		target, body, ctype string
+		code                int
+	}{
+		{"/missing", "<h1>not found</h1>", "text/html; charset=utf-8", http.StatusNotFound},
+		{"/private/a.txt", "<h1>forbidden</h1>", "text/html; charset=utf-8", http.StatusForbidden},
+		{"/broken/", "<!DOCTYPE html><h1>error</h1>", "text/html; charset=utf-8", http.StatusInternalServerError},
+		{"/locked/a.txt", "Unauthorized\n", "text/plain; charset=utf-8", http.StatusUnauthorized},
+		{"/a.txt", "a", "text/plain; charset=utf-8", http.StatusOK},
+	} {
+		w := serve(fs, http.MethodGet, c.target)
+		expect(t, w, c.code, c.body)
+		if got := w.Header().Get("Content-Type"); got != c.ctype {
+			t.Errorf("%s: Content-Type = %q, want %q", c.target, got, c.ctype)
+		}
+		if c.code != http.StatusOK && w.Header().Get("ETag")+w.Header().Get("Last-Modified") != "" {
+			t.Errorf("%s: error page carries validators", c.target)
+		}
+	}
+	w := serve(fs, http.MethodHead, "/missing")
+	expect(t, w, http.StatusNotFound, "")
+	if w.Body.Len() != 0 {
+		t.Errorf("HEAD error page has a body")
+	}
+
+	// Requests for the pages themselves stay hidden
+	expect(t, serve(fs, http.MethodGet, "/.errors/403.html"), http.StatusNotFound, "<h1>not found</h1>")
+}
+
+func TestErrorPagesMissing(t *testing.T) {
+	fs := New(MapFS(nil), testNotFound, WithErrorPages(map[int]string{http.StatusNotFound: "/404.html"}))
+	expect(t, serve(fs, http.MethodGet, "/missing"), http.StatusNotFound, notFoundBody)
+}
+
+func TestFraming(t *testing.T) {
+	files := MapFS(map[string]string{"docs/index.html": "docs", ".errors/404.html": "<h1>not found</h1>"})
+	for _, tc := range []struct {
+		name   string
+		fs     *FileSystemWith404
+		method string
+		target string
+		code   int
+		length string
+	}{
+		{"redirect", New(files, testNotFound), http.MethodGet, "/docs", http.StatusMovedPermanently, "0"},
+		{"method", New(files, testNotFound, WithAllowedMethods(http.MethodGet)), http.MethodPost, "/docs/", http.StatusMethodNotAllowed, "19"},
+		{"error page", New(files, testNotFound, WithErrorPages(map[int]string{http.StatusNotFound: "/.errors/404.html"})), http.MethodGet, "/missing", http.StatusNotFound, "18"},
+	} {
+		w := serve(tc.fs, tc.method, tc.target)
+		if w.Code != tc.code {
+			t.Errorf("%s: status %d, want %d", tc.name, w.Code, tc.code)
+		}
+		if got := w.Header().Get("Content-Length"); got != tc.length {
+			t.Errorf("%s: Content-Length %q, want %q", tc.name, got, tc.length)
+		}
+		if got := fmt.Sprint(w.Body.Len()); got != tc.length {
+			t.Errorf("%s: body of %s bytes, want %s", tc.name, got, tc.length)
+		}
+	}
+}
+
+func TestFramingKeepAlive(t *testing.T) {
+	files := MapFS(map[string]string{"docs/index.html": "docs"})
+	srv := httptest.NewServer(New(files, testNotFound, WithAllowedMethods(http.MethodGet)))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Every response must keep the HTTP/1.0 connection open for the next
+	br := bufio.NewReader(conn)
+	for _, req := range []struct {
+		method, target string
+		code           int
+	}{
+		{http.MethodGet, "/docs", http.StatusMovedPermanently},
+		{http.MethodGet, "/missing", http.StatusNotFound},
+		{http.MethodPost, "/docs/", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/docs/", http.StatusOK},
+	} {
+		fmt.Fprintf(conn, "%s %s HTTP/1.0\r\nHost: test\r\nConnection: keep-alive\r\n\r\n", req.method, req.target)
+		res, err := http.ReadResponse(br, nil)
+		if err != nil {
+			t.Fatalf("%s %s: %v", req.method, req.target, err)
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		if res.StatusCode != req.code {
+			t.Errorf("%s %s: status %d, want %d", req.method, req.target, res.StatusCode, req.code)
+		}
+		if res.ContentLength < 0 {
+			t.Errorf("%s %s: no Content-Length", req.method, req.target)
+		}
+		if res.Close {
+			t.Fatalf("%s %s: connection closed by the server", req.method, req.target)
+		}
+	}
+}
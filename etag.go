@@ -0,0 +1,177 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// precomputeWorkers is the number of files PrecomputeETags hashes at once
+	precomputeWorkers = 4
+	// maxPrecomputeSize is the size of files left to be hashed on demand
+	maxPrecomputeSize = 16 << 20
+)
+
+// etagEntry is a cached content hash ETag of a file
+type etagEntry struct {
+	modTime time.Time
+	size    int64
+	tag     string
+}
+
+// etagCache stores the ETags of files by name. An entry is only valid
+// while the ModTime and size of the file stay the same.
+type etagCache struct {
+	mu      sync.Mutex
+	entries map[string]etagEntry
+}
+
+// get returns the cached ETag of the file if it is still valid
+func (c *etagCache) get(name string, modTime time.Time, size int64) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[name]
+	if !ok || !e.modTime.Equal(modTime) || e.size != size {
+		return "", false
+	}
+	return e.tag, true
+}
+
+// put stores the ETag of the file
+func (c *etagCache) put(name string, modTime time.Time, size int64, tag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]etagEntry)
+	}
+	c.entries[name] = etagEntry{modTime: modTime, size: size, tag: tag}
+}
+
+// delete drops the ETag of the file
+func (c *etagCache) delete(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, name)
+}
+
+// clear drops all ETags
+func (c *etagCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// hashETag computes a strong ETag from the content and rewinds it
+func hashETag(content io.ReadSeeker) (string, error) {
+	sum, err := hashContent(content)
+	if err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// hashContent returns the SHA-256 hash of the content and rewinds it
+func hashContent(content io.ReadSeeker) ([]byte, error) {
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return nil, err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// etagVariant derives the ETag of an encoded variant of the content,
+// e.g. `"abc"` becomes `"abc-gzip"` and `W/"abc"` becomes `W/"abc-gzip"`.
+func etagVariant(etag, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// PrecomputeETags hashes the servable files up front and caches their
+// ETags, so the first requests are not slowed down by hashing. Files over
+// 16 MiB are left to be hashed on their first request. It does nothing
+// without WithETag, or with WithReadSeekerTransform whose ETags are not
+// cached.
+func (fs *FileSystemWith404) PrecomputeETags() error {
+	if !fs.etag || fs.transform != nil {
+		return nil
+	}
+
+	ctx := context.Background()
+	type file struct {
+		name string
+		info os.FileInfo
+	}
+	var files []file
+	err := fs.walkDir(ctx, "/", nil, func(upath string, d os.FileInfo) error {
+		// Index pages are also visited by their file name
+		if !strings.HasSuffix(upath, "/") && d.Size() <= maxPrecomputeSize {
+			files = append(files, file{upath, d})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, precomputeWorkers)
+	for _, f := range files {
+		if _, ok := fs.etags.get(f.name, f.info.ModTime(), f.info.Size()); ok {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(f file) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := fs.precomputeETag(ctx, f.name); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(f)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// precomputeETag hashes the named file into the ETag cache
+func (fs *FileSystemWith404) precomputeETag(ctx context.Context, name string) error {
+	f, d, err := fs.open(ctx, name, nil)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tag, err := hashETag(f)
+	if err != nil {
+		return err
+	}
+	fs.etags.put(name, d.ModTime(), d.Size(), tag)
+	return nil
+}
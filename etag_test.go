@@ -0,0 +1,188 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestETagVariant(t *testing.T) {
+	for etag, want := range map[string]string{
+		`"abc"`:   `"abc-gzip"`,
+		`W/"abc"`: `W/"abc-gzip"`,
+		`bogus`:   `bogus`,
+	} {
+		if got := etagVariant(etag, "gzip"); got != want {
+			t.Errorf("etagVariant(%s) = %s, want %s", etag, got, want)
+		}
+	}
+}
+
+func TestETagByEncoding(t *testing.T) {
+	text := strings.Repeat("compressible text ", 100)
+	fs := New(MapFS(map[string]string{"a.txt": text}), testNotFound, WithETag(true), WithGzip(true))
+
+	identity := serve(fs, http.MethodGet, "/a.txt")
+	expect(t, identity, http.StatusOK, text)
+	plainTag := identity.Header().Get("ETag")
+
+	gzipped := serve(fs, http.MethodGet, "/a.txt", "Accept-Encoding", "gzip")
+	expect(t, gzipped, http.StatusOK, "")
+	gzipTag := gzipped.Header().Get("ETag")
+	if plainTag == "" || gzipTag != etagVariant(plainTag, "gzip") {
+		t.Fatalf("ETags %s and %s, want the -gzip variant", plainTag, gzipTag)
+	}
+	zr, err := gzip.NewReader(gzipped.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != text {
+		t.Errorf("decompressed body differs")
+	}
+	for _, w := range []*http.Response{identity.Result(), gzipped.Result()} {
+		if got := w.Header.Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("Vary = %q", got)
+		}
+	}
+
+	for _, c := range []struct {
+		encoding, tag string
+		code          int
+	}{
+		{"", plainTag, http.StatusNotModified},
+		{"", gzipTag, http.StatusOK},
+		{"gzip", gzipTag, http.StatusNotModified},
+		{"gzip", plainTag, http.StatusOK},
+	} {
+		w := serve(fs, http.MethodGet, "/a.txt", "Accept-Encoding", c.encoding, "If-None-Match", c.tag)
+		if w.Code != c.code {
+			t.Errorf("Accept-Encoding %q If-None-Match %s: %d, want %d", c.encoding, c.tag, w.Code, c.code)
+		}
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	fs := New(MapFS(map[string]string{"a.zip": "archive"}), testNotFound, WithETag(true))
+	tag := serve(fs, http.MethodGet, "/a.zip").Header().Get("ETag")
+	if tag == "" {
+		t.Fatal("no ETag")
+	}
+	for header, code := range map[string]int{
+		tag:                      http.StatusOK,
+		`"other", ` + tag:        http.StatusOK,
+		"*":                      http.StatusOK,
+		`"other"`:                http.StatusPreconditionFailed,
+		"W/" + tag:               http.StatusPreconditionFailed,
+		etagVariant(tag, "gzip"): http.StatusPreconditionFailed,
+	} {
+		w := serve(fs, http.MethodGet, "/a.zip", "If-Match", header)
+		if w.Code != code {
+			t.Errorf("If-Match %s: %d, want %d", header, w.Code, code)
+		}
+		if code == http.StatusOK && w.Body.String() != "archive" {
+			t.Errorf("If-Match %s: body %q", header, w.Body.String())
+		}
+	}
+	w := serve(fs, http.MethodGet, "/a.zip", "If-Match", tag, "Range", "bytes=0-2")
+	expect(t, w, http.StatusPartialContent, "arc")
+
+	// Without an ETag only the wildcard matches
+	fs = New(MapFS(map[string]string{"a.zip": "archive"}), testNotFound)
+	expect(t, serve(fs, http.MethodGet, "/a.zip", "If-Match", "*"), http.StatusOK, "archive")
+	expect(t, serve(fs, http.MethodGet, "/a.zip", "If-Match", tag), http.StatusPreconditionFailed, "")
+}
+
+// peakFS counts the files open at once, holding each open for a while
+type peakFS struct {
+	http.FileSystem
+	mu     sync.Mutex
+	active int
+	peak   int
+}
+
+type peakFile struct {
+	http.File
+	fs *peakFS
+}
+
+func (p *peakFS) Open(name string) (http.File, error) {
+	f, err := p.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if d, err := f.Stat(); err != nil || d.IsDir() {
+		return f, err
+	}
+	p.mu.Lock()
+	p.active++
+	if p.active > p.peak {
+		p.peak = p.active
+	}
+	p.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	return peakFile{f, p}, nil
+}
+
+func (f peakFile) Close() error {
+	f.fs.mu.Lock()
+	f.fs.active--
+	f.fs.mu.Unlock()
+	return f.File.Close()
+}
+
+func TestPrecomputeETags(t *testing.T) {
+	files := map[string]string{
+		"index.html": "home",
+		".env":       "secret",
+		"huge.bin":   strings.Repeat("x", maxPrecomputeSize+1),
+	}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("assets/%02d.js", i)] = fmt.Sprint(i)
+	}
+	root := &peakFS{FileSystem: MapFS(files)}
+	fs := New(root, testNotFound, WithETag(true), WithServerTiming(true))
+	if err := fs.PrecomputeETags(); err != nil {
+		t.Fatal(err)
+	}
+	if root.peak > precomputeWorkers {
+		t.Errorf("%d files hashed at once, want at most %d", root.peak, precomputeWorkers)
+	}
+	if len(fs.etags.entries) != 21 {
+		t.Errorf("%d ETags cached, want 21", len(fs.etags.entries))
+	}
+	for _, name := range []string{"/.env", "/huge.bin"} {
+		if _, ok := fs.etags.entries[name]; ok {
+			t.Errorf("ETag of %s precomputed", name)
+		}
+	}
+
+	// The first requests are answered from the cache
+	for _, target := range []string{"/", "/index.html", "/assets/07.js"} {
+		w := serve(fs, http.MethodGet, target)
+		expect(t, w, http.StatusOK, "")
+		if timing := w.Header().Get("Server-Timing"); !strings.Contains(timing, `cache;desc="hit"`) {
+			t.Errorf("%s: Server-Timing %q, want a cache hit", target, timing)
+		}
+		if w.Header().Get("ETag") == "" {
+			t.Errorf("%s: no ETag", target)
+		}
+	}
+	if timing := serve(fs, http.MethodGet, "/huge.bin").Header().Get("Server-Timing"); !strings.Contains(timing, `cache;desc="miss"`) {
+		t.Errorf("huge file Server-Timing %q, want a cache miss", timing)
+	}
+
+	// Without ETags nothing is hashed
+	fs = New(MapFS(files), testNotFound)
+	if err := fs.PrecomputeETags(); err != nil || len(fs.etags.entries) != 0 {
+		t.Errorf("PrecomputeETags without WithETag cached %d, %v", len(fs.etags.entries), err)
+	}
+}
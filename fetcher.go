@@ -0,0 +1,148 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+)
+
+// Fetcher retrieves files for serving from any backend, like an object
+// storage or a pull-through cache. The name is a cleaned slash separated
+// path starting with '/'. Directories are reported through the IsDir of the
+// returned os.FileInfo, their content may be nil. If the returned content
+// implements io.Closer it is closed once the request is served. Missing
+// files should be reported using an error wrapping os.ErrNotExist, any
+// other error is reported as a BackendError.
+type Fetcher interface {
+	Fetch(ctx context.Context, name string) (io.ReadSeeker, os.FileInfo, error)
+}
+
+// BackendError reports a failure of the backend holding the files, like an
+// unreachable object storage. A lookup failing with it is answered with
+// status 500 instead of the notFound handler. Any other error opening a
+// file, like for an invalid or too long name, is treated as a missing file.
+// A http.FileSystem may return it to report its own failures.
+type BackendError struct {
+	Name string
+	Err  error
+}
+
+func (e *BackendError) Error() string {
+	return "filesys404: backend failed to open " + e.Name + ": " + e.Err.Error()
+}
+
+func (e *BackendError) Unwrap() error {
+	return e.Err
+}
+
+// isBackendError reports if the error is a failure of the backend
+func isBackendError(err error) bool {
+	var be *BackendError
+	return errors.As(err, &be)
+}
+
+// NewFetcher creates a new FileSystem404 instance serving the files
+// retrieved using the Fetcher. All features available for http.FileSystem
+// based instances apply, except directory listing which needs the fetched
+// directories to implement Readdir like http.File does.
+func NewFetcher(f Fetcher, notFound http.HandlerFunc, opts ...Option) *FileSystemWith404 {
+	return New(&fetcherFS{fetcher: f}, notFound, opts...)
+}
+
+// FileSystemFetcher adapts the http.FileSystem to a Fetcher
+func FileSystemFetcher(fsys http.FileSystem) Fetcher {
+	return fileSystemFetcher{fsys: fsys}
+}
+
+// fileSystemFetcher is the Fetcher using a http.FileSystem
+type fileSystemFetcher struct {
+	fsys http.FileSystem
+}
+
+func (f fileSystemFetcher) Fetch(_ context.Context, name string) (io.ReadSeeker, os.FileInfo, error) {
+	file, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, d, nil
+}
+
+// contextOpener is implemented by file systems using the request context
+type contextOpener interface {
+	openContext(ctx context.Context, name string) (http.File, error)
+}
+
+// fetcherFS presents a Fetcher as a http.FileSystem
+type fetcherFS struct {
+	fetcher Fetcher
+}
+
+// Open fetches the named file without a request context
+func (fsys *fetcherFS) Open(name string) (http.File, error) {
+	return fsys.openContext(context.Background(), name)
+}
+
+func (fsys *fetcherFS) openContext(ctx context.Context, name string) (http.File, error) {
+	content, d, err := fsys.fetcher.Fetch(ctx, name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) || isBackendError(err) {
+			return nil, err
+		}
+		return nil, &BackendError{Name: name, Err: err}
+	}
+	return &fetchedFile{content: content, info: d}, nil
+}
+
+// errNotReadable is returned when reading a fetched directory
+var errNotReadable = errors.New("filesys404: fetched file has no content")
+
+// fetchedFile presents the result of a Fetch as a http.File
+type fetchedFile struct {
+	content io.ReadSeeker
+	info    os.FileInfo
+}
+
+func (f *fetchedFile) Read(p []byte) (int, error) {
+	if f.content == nil {
+		return 0, errNotReadable
+	}
+	return f.content.Read(p)
+}
+
+func (f *fetchedFile) Seek(offset int64, whence int) (int64, error) {
+	if f.content == nil {
+		return 0, errNotReadable
+	}
+	return f.content.Seek(offset, whence)
+}
+
+func (f *fetchedFile) Close() error {
+	if c, ok := f.content.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (f *fetchedFile) Readdir(count int) ([]os.FileInfo, error) {
+	if d, ok := f.content.(interface {
+		Readdir(count int) ([]os.FileInfo, error)
+	}); ok {
+		return d.Readdir(count)
+	}
+	return nil, errors.New("filesys404: fetched directory can not be listed")
+}
+
+func (f *fetchedFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// objectKey is the request context key checked by the objectFetcher
+type objectKey struct{}
+
+// objectFetcher serves objects by key like an object storage, with the
+// directories implied by the keys
+type objectFetcher struct {
+	objects map[string]string
+
+	mu      sync.Mutex
+	fetched []string
+	ctxs    []interface{}
+	open    int
+}
+
+// objectReader is the content of a fetched object, counting the open ones
+type objectReader struct {
+	*strings.Reader
+	f *objectFetcher
+}
+
+func (r objectReader) Close() error {
+	r.f.mu.Lock()
+	defer r.f.mu.Unlock()
+	r.f.open--
+	return nil
+}
+
+func (f *objectFetcher) Fetch(ctx context.Context, name string) (io.ReadSeeker, os.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetched = append(f.fetched, name)
+	f.ctxs = append(f.ctxs, ctx.Value(objectKey{}))
+
+	base := path.Base(name)
+	modTime := time.Unix(1600000000, 0)
+	if content, ok := f.objects[strings.TrimPrefix(name, "/")]; ok {
+		f.open++
+		return objectReader{strings.NewReader(content), f}, mapInfo{name: base, size: int64(len(content)), mode: 0644, modTime: modTime}, nil
+	}
+	prefix := strings.TrimPrefix(name, "/") + "/"
+	for key := range f.objects {
+		if name == "/" || strings.HasPrefix(key, prefix) {
+			return nil, mapInfo{name: base, mode: os.ModeDir | 0755, modTime: modTime}, nil
+		}
+	}
+	return nil, nil, os.ErrNotExist
+}
+
+func TestNewFetcher(t *testing.T) {
+	fetcher := &objectFetcher{objects: map[string]string{
+		"index.html":      "home",
+		"docs/index.html": "docs",
+		"docs/a.txt":      "a",
+		".env":            "secret",
+		"app/.git/config": "secret",
+	}}
+	fs := NewFetcher(fetcher, testNotFound, WithETag(true))
+
+	expect(t, serve(fs, http.MethodGet, "/"), http.StatusOK, "home")
+	expect(t, serve(fs, http.MethodGet, "/docs/"), http.StatusOK, "docs")
+	expect(t, serve(fs, http.MethodGet, "/docs"), http.StatusMovedPermanently, "")
+	expect(t, serve(fs, http.MethodGet, "/docs/a.txt"), http.StatusOK, "a")
+	expect(t, serve(fs, http.MethodGet, "/missing"), http.StatusNotFound, notFoundBody)
+
+	// Hidden names are not even fetched
+	fetcher.fetched = nil
+	for _, target := range []string{"/.env", "/app/.git/config"} {
+		expect(t, serve(fs, http.MethodGet, target), http.StatusNotFound, notFoundBody)
+	}
+	for _, name := range fetcher.fetched {
+		if strings.Contains(name, "/.") {
+			t.Errorf("hidden %s fetched", name)
+		}
+	}
+
+	// The ETag of the content is cached and validated
+	tag := serve(fs, http.MethodGet, "/docs/a.txt").Header().Get("ETag")
+	if tag == "" {
+		t.Fatalf("no ETag")
+	}
+	expect(t, serve(fs, http.MethodGet, "/docs/a.txt", "If-None-Match", tag), http.StatusNotModified, "")
+
+	// Every fetched content is closed
+	if fetcher.open != 0 {
+		t.Errorf("%d fetched objects left open", fetcher.open)
+	}
+
+	// The request context reaches the backend
+	fetcher.ctxs = nil
+	r := httptest.NewRequest(http.MethodGet, "/docs/a.txt", nil)
+	fs.ServeHTTP(httptest.NewRecorder(), r.WithContext(context.WithValue(r.Context(), objectKey{}, "request")))
+	if len(fetcher.ctxs) == 0 {
+		t.Fatalf("nothing fetched")
+	}
+	for _, v := range fetcher.ctxs {
+		if v != "request" {
+			t.Errorf("fetched without the request context")
+		}
+	}
+}
+
+func TestFileSystemFetcher(t *testing.T) {
+	f := FileSystemFetcher(MapFS(map[string]string{"docs/a.txt": "a"}))
+	content, d, err := f.Fetch(context.Background(), "/docs/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(content)
+	if string(data) != "a" || d.Name() != "a.txt" || d.Size() != 1 {
+		t.Errorf("fetched %q as %s of %d bytes", data, d.Name(), d.Size())
+	}
+	if _, d, err := f.Fetch(context.Background(), "/docs"); err != nil || !d.IsDir() {
+		t.Errorf("directory fetched as %v, %v", d, err)
+	}
+	if _, _, err := f.Fetch(context.Background(), "/missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file fetched with %v", err)
+	}
+}
@@ -32,96 +32,576 @@ Docs available at : https://pkg.go.dev/github.com/boseji/filesys404
 package filesys404
 
 import (
+	"bytes"
+	"context"
+	"html/template"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"os"
 	"path"
 	"strings"
+	"sync"
+	"time"
 )
 
+// defaultIndexPage is the index file served for directory requests
+// when no other index pages are configured.
+const defaultIndexPage = "index.html"
+
+// sniffLen is the amount of content inspected to detect its type,
+// the same as used by http.DetectContentType.
+const sniffLen = 512
+
 // FileSystemWith404 stores the supplied static file system and
 // the custom Not found handler.
 type FileSystemWith404 struct {
-	root     http.FileSystem
-	notFound http.HandlerFunc
+	mu                   sync.RWMutex
+	root                 http.FileSystem
+	notFound             http.HandlerFunc
+	indexPages           []string
+	indexResolver        func(dir string) []string
+	serverTiming         bool
+	languageNegotiation  bool
+	spaFallback          string
+	spaBase              string
+	etag                 bool
+	etags                etagCache
+	gzip                 bool
+	gzipLevel            int
+	indexWithoutRedirect bool
+	latency              *latencyHistogram
+	dotFileHandler       http.HandlerFunc
+	contentTypeResolver  func(name string, peek []byte) string
+	limiter              *serveLimiter
+	disableRange         func(name string) bool
+	csp                  string
+	cspNonce             bool
+	rejectEmpty          bool
+	headersFile          *ruleFile
+	redirectsFile        *ruleFile
+	canonicalHost        string
+	canonicalExempt      []string
+	trustForwarded       bool
+	directoryDefault     func(dir string) string
+	directoryDefaultFile string
+	errorPages           map[int]string
+	tryFiles             []string
+	hiddenDirsOnly       bool
+	pathRewriter         func(upath string) string
+	rejectSpecial        bool
+	transform            func(name string, rs io.ReadSeeker) (io.ReadSeeker, error)
+	transforms           transformCache
+	listing              bool
+	redirectIndex        bool
+	magicDetection       bool
+	rejectReserved       bool
+	robotsFallback       string
+	bufferTransformed    int
+	denyGlobs            []string
+	allowGlobs           []string
+	throttle             int64
+	immutablePrefix      string
+	immutableStrip       bool
+	hotlinkAllowed       []string
+	hotlinkEmpty         bool
+	hotlinkSameOrigin    bool
+	rootHandler          http.Handler
+	preloadLinks         func(name string) []string
+	earlyHints           bool
+	cacheBustParam       string
+	bufferNonSeekable    int
+	aliases              *aliases
+	tracer               Tracer
+	manifest             *assetManifest
+	maxFileSize          int64
+	allowedMethods       []string
+	notFoundDelay        time.Duration
+	notFoundJitter       time.Duration
+	notFoundRoutes       []notFoundRoute
+	zeroCopyThreshold    int64
+	cacheStatusHeader    string
+	cspReportOnly        string
+	canonicalPolicy      CanonicalURLPolicy
+	typeHeaders          map[string][]HeaderKV
+	serveGuard           func(r *http.Request, info os.FileInfo) (bool, int)
+	closed               int32
+	listingTemplate      *template.Template
+	listingMatcher       func(dir string) bool
+	listingSort          func(a, b os.FileInfo) bool
+	now                  func() time.Time
 }
 
 // New creates a new FileSystem404 instance
-func New(r http.FileSystem, notFound http.HandlerFunc) *FileSystemWith404 {
-	return &FileSystemWith404{
-		root:     r,
-		notFound: notFound,
+func New(r http.FileSystem, notFound http.HandlerFunc, opts ...Option) *FileSystemWith404 {
+	fs := &FileSystemWith404{
+		root:              r,
+		notFound:          notFound,
+		indexPages:        []string{defaultIndexPage},
+		rejectSpecial:     true,
+		hotlinkEmpty:      true,
+		hotlinkSameOrigin: true,
+		now:               time.Now,
+	}
+	for _, opt := range opts {
+		opt(fs)
+	}
+
+	// Parse the configuration files at startup
+	if fs.headersFile != nil {
+		fs.headersFile.get(context.Background(), fs)
 	}
+	if fs.redirectsFile != nil {
+		fs.redirectsFile.get(context.Background(), fs)
+	}
+	return fs
+}
+
+// SetNotFound replaces the not found handler. It is safe to call while
+// requests are being served, each request uses the handler that was
+// active when it arrived.
+func (fs *FileSystemWith404) SetNotFound(notFound http.HandlerFunc) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.notFound = notFound
+}
+
+// SetRoot replaces the file system files are served from, like after
+// deploying a new release to another directory. Everything cached from the
+// previous root is dropped. Requests in flight may still read some files
+// from the previous root.
+func (fs *FileSystemWith404) SetRoot(root http.FileSystem) {
+	fs.mu.Lock()
+	fs.root = root
+	fs.mu.Unlock()
+	fs.InvalidateAll()
+}
+
+// Root returns the currently active file system, for composing handlers
+// that need to read from the same files.
+func (fs *FileSystemWith404) Root() http.FileSystem {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.root
+}
+
+// notFoundHandler returns the currently active not found handler
+func (fs *FileSystemWith404) notFoundHandler() http.HandlerFunc {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.notFound
 }
 
 // ServeHTTP is the implementation of the Handler interface
 func (fs *FileSystemWith404) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	const indexPage = "/index.html"
-	// Find out the Path
-	upath := r.URL.Path
-	if !strings.HasPrefix(upath, "/") {
-		upath = "/" + upath
-		r.URL.Path = upath
+	if fs.unavailable(w, r) || fs.serverOptions(w, r) || fs.methodNotAllowed(w, r) {
+		return
 	}
-	upath = path.Clean(upath)
 
-	// Filter out .files or hidden dot files
-	dotFound := false
-	for _, p := range strings.Split(r.URL.Path, "/")[1:] {
-		if strings.HasPrefix(p, ".") {
-			dotFound = true
-			break
-		}
+	if fs.latency != nil {
+		start := fs.now()
+		defer func() {
+			fs.latency.observe(fs.now().Sub(start))
+		}()
 	}
-	if dotFound {
-		fs.notFound(w, r)
+
+	st := fs.newTiming()
+	w = st.wrap(w)
+	tr, w, r := fs.startTrace(w, r)
+	defer tr.end(fs, st)
+
+	if fs.cacheBustParam != "" {
+		w, r = fs.cacheBust(w, r)
+	}
+
+	if fs.pathRewriter != nil {
+		r = rewritePath(r, fs.pathRewriter(r.URL.Path))
+	}
+
+	// The not found route is chosen by the path resolved
+	notFound := fs.notFoundHandler()
+	if fs.notFoundRoutes != nil {
+		notFound = fs.notFoundFor(path.Clean("/"+r.URL.Path), notFound)
+	}
+
+	if fs.canonicalHost != "" && fs.redirectCanonicalHost(w, r) {
 		return
 	}
 
-	// Replace or Dir Lising to Index Pages
-	if strings.HasSuffix(r.URL.Path, "/") {
-		upath = path.Join(r.URL.Path, indexPage)
+	if fs.csp != "" || fs.cspReportOnly != "" {
+		var err error
+		if r, err = fs.setCSP(w, r); err != nil {
+			fs.fail(w, r, http.StatusInternalServerError, nil)
+			return
+		}
 	}
 
-	// Try to Open the File
-	f, err := fs.root.Open(upath)
+	res, err := fs.resolve(r, st)
+	tr.resolved(&res, err)
 	if err != nil {
-		// Else its actually an Invalid file
-		fs.notFound(w, r)
+		if r.Context().Err() == nil {
+			fs.fail(w, r, http.StatusInternalServerError, notFound)
+		}
+		return
+	}
+	defer res.close()
+	if res.path != r.URL.Path {
+		r = rewritePath(r, res.path)
+	}
+
+	switch res.kind {
+	case resolveHidden:
+		if fs.dotFileHandler != nil {
+			fs.dotFileHandler(w, r)
+			return
+		}
+		fs.fail(w, r, http.StatusNotFound, notFound)
+	case resolveRedirect:
+		if res.local {
+			localRedirect(w, res.location, res.status)
+			return
+		}
+		http.Redirect(w, r, res.location, res.status)
+	case resolveError:
+		fs.fail(w, r, res.status, notFound)
+	case resolveFallback:
+		fs.serveFallback(w, r, res, notFound, st)
+	case resolveFile:
+		fs.serveFile(w, r, res, st)
+	case resolveListing:
+		fs.serveListing(w, r, res)
+	case resolveRoot:
+		fs.rootHandler.ServeHTTP(w, r)
+	case resolveManifest:
+		fs.serveManifest(w, r, notFound)
+	case resolveRobots:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.ServeContent(w, r, robotsPath, time.Time{}, strings.NewReader(fs.robotsFallback))
+	default:
+		fs.fail(w, r, http.StatusNotFound, notFound)
+	}
+}
+
+// rewritePath returns a shallow copy of the request for another path
+func rewritePath(r *http.Request, upath string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = upath
+	r2.URL.RawPath = ""
+	return r2
+}
+
+// admit runs the checks and headers shared by all served files, before
+// any of the content is read. It reports if the file is still to be
+// served, else the response has been written.
+func (fs *FileSystemWith404) admit(w http.ResponseWriter, r *http.Request, res resolution) bool {
+	if fs.hotlinkAllowed != nil && fs.hotlinked(r, res.name) {
+		fs.fail(w, r, http.StatusForbidden, nil)
+		return false
+	}
+	if fs.serveGuard != nil {
+		if ok, code := fs.serveGuard(r, res.info); !ok {
+			if code == 0 {
+				code = http.StatusForbidden
+			}
+			fs.fail(w, r, code, nil)
+			return false
+		}
+	}
+	if res.immutable {
+		w.Header().Set("Cache-Control", immutableCacheControl)
+	}
+	if fs.preloadLinks != nil {
+		fs.addPreloadLinks(w, r, res.name)
+	}
+	return true
+}
+
+// serveFallback writes the SPA fallback page, with the base href
+// injected when configured.
+func (fs *FileSystemWith404) serveFallback(w http.ResponseWriter, r *http.Request, res resolution, notFound http.HandlerFunc, st *serverTiming) {
+	if !fs.admit(w, r, res) {
+		return
+	}
+	release, ok := fs.acquireSlot(w, r)
+	if !ok {
 		return
 	}
-	defer f.Close()
+	defer release()
 
-	d, err := f.Stat()
+	if fs.spaBase == "" {
+		fs.serveContent(w, r, res.name, res.info, res.file, true, st)
+		return
+	}
+
+	doc, err := io.ReadAll(res.file)
 	if err != nil {
-		fs.notFound(w, r)
+		fs.fail(w, r, http.StatusNotFound, notFound)
+		return
+	}
+	doc = injectBaseHref(doc, fs.spaBase)
+	fs.serveContent(w, r, res.name, res.info, bytes.NewReader(doc), false, st)
+}
+
+// serveFile writes the content of the resolved file to the response
+func (fs *FileSystemWith404) serveFile(w http.ResponseWriter, r *http.Request, res resolution, st *serverTiming) {
+	if !fs.admit(w, r, res) {
+		return
+	}
+	if fs.languageNegotiation {
+		addVary(w.Header(), "Accept-Language")
+		if res.language != "" {
+			w.Header().Set("Content-Language", res.language)
+		}
+	}
+
+	// The content is only read holding a slot
+	release, ok := fs.acquireSlot(w, r)
+	if !ok {
 		return
 	}
+	defer release()
+
+	var content io.ReadSeeker = res.file
+	if _, err := res.file.Seek(0, io.SeekStart); err != nil {
+		buffered, stream, err := fs.bufferUnseekable(res.file)
+		if err != nil {
+			fs.fail(w, r, http.StatusInternalServerError, nil)
+			return
+		}
+		if stream != nil {
+			fs.writeContent(w, r, res.name, res.info, nil, stream, false, st)
+			return
+		}
+		content = buffered
+	}
+
+	cacheable := true
+	if fs.transform != nil {
+		transformed, err := fs.transformContent(res.name, res.info, content, cacheBusted(r), st)
+		if err != nil {
+			fs.fail(w, r, http.StatusInternalServerError, nil)
+			return
+		}
+		content, cacheable = transformed, false
+	}
+	if res.baseHref != "" && strings.HasPrefix(mime.TypeByExtension(path.Ext(res.name)), "text/html") {
+		doc, err := io.ReadAll(content)
+		if err != nil {
+			fs.fail(w, r, http.StatusInternalServerError, nil)
+			return
+		}
+		// A <base> of the page itself is kept
+		if !baseTag.Match(doc) {
+			doc = injectBaseHref(doc, res.baseHref)
+		}
+		content, cacheable = bytes.NewReader(doc), false
+	}
+	fs.serveContent(w, r, res.name, res.info, content, cacheable, st)
+}
+
+// serveContent writes the content of the named file applying the
+// configured validators and encodings. The ETag of content read as is
+// from the file system is cached, while transformed content is hashed
+// on each request.
+func (fs *FileSystemWith404) serveContent(w http.ResponseWriter, r *http.Request, name string, d os.FileInfo, content io.ReadSeeker, cacheable bool, st *serverTiming) {
+	fs.writeContent(w, r, name, d, content, nil, cacheable, st)
+}
+
+// acquireSlot takes a slot of WithMaxConcurrentServes before any content
+// is read. It reports false once the response has been written, or the
+// client is gone, and else returns the function releasing the slot.
+func (fs *FileSystemWith404) acquireSlot(w http.ResponseWriter, r *http.Request) (func(), bool) {
+	if fs.limiter == nil {
+		return func() {}, true
+	}
+	if !fs.limiter.acquire(r.Context()) {
+		if r.Context().Err() == nil {
+			fs.fail(w, r, http.StatusServiceUnavailable, nil)
+		}
+		return nil, false
+	}
+	return fs.limiter.release, true
+}
+
+// writeContent writes either the content able to seek, or the stream of
+// a file unable to, with the same headers and encodings. Streams are sent
+// without validators nor Range support.
+func (fs *FileSystemWith404) writeContent(w http.ResponseWriter, r *http.Request, name string, d os.FileInfo, content io.ReadSeeker, stream io.Reader, cacheable bool, st *serverTiming) {
+	if fs.throttle > 0 {
+		w = newThrottledWriter(r.Context(), w, fs.throttle)
+	}
+
+	h := w.Header()
+	cacheable = cacheable && !cacheBusted(r)
+
+	// The head of the content is read once for all type detections
+	var head []byte
+	var headErr error
+	sniffed := false
+	sniff := func() ([]byte, error) {
+		if !sniffed {
+			sniffed = true
+			if content != nil {
+				head, headErr = peek(content, sniffLen)
+			} else {
+				head, headErr = peekStream(&stream, sniffLen)
+			}
+		}
+		return head, headErr
+	}
+
+	if fs.contentTypeResolver != nil && h.Get("Content-Type") == "" {
+		if head, err := sniff(); err == nil {
+			if ctype := fs.contentTypeResolver(name, head); ctype != "" {
+				h.Set("Content-Type", ctype)
+			}
+		}
+	}
+
+	if fs.magicDetection && h.Get("Content-Type") == "" && path.Ext(name) == "" {
+		if head, err := sniff(); err == nil {
+			if ctype := magicType(head); ctype != "" {
+				h.Set("Content-Type", ctype)
+			}
+		}
+	}
+
+	if fs.disableRange != nil && fs.disableRange(name) {
+		r.Header.Del("Range")
+		r.Header.Del("If-Range")
+		w = onWriteHeader(w, func(h http.Header, _ int) {
+			h.Set("Accept-Ranges", "none")
+		})
+	}
+
+	if fs.headersFile != nil {
+		if rules, ok := fs.headersFile.get(r.Context(), fs).([]*headerRule); ok {
+			applyHeaderRules(h, rules, r.URL.Path)
+		}
+	}
 
-	if d.IsDir() {
-		f.Close() // Force Close the Directory
+	ctype := h.Get("Content-Type")
+	if ctype == "" {
+		ctype = mime.TypeByExtension(path.Ext(name))
+	}
 
-		// Check if its just a Dir name that might contain an Index file
-		url := r.URL.Path
-		if url[len(url)-1] != '/' { // Does not have a '/' at the end
-			p := path.Base(url) + "/"
-			localRedirect(w, r, p)
+	if ctype == "" && (fs.typeHeaders != nil || stream != nil) {
+		// The type http.ServeContent would sniff
+		if head, err := sniff(); err == nil {
+			ctype = http.DetectContentType(head)
+			h.Set("Content-Type", ctype)
+		}
+	}
+	if fs.typeHeaders != nil {
+		applyTypeHeaders(h, fs.typeHeaders, ctype)
+	}
+
+	etag, modTime := fs.etag, d.ModTime()
+	if nonce := CSPNonce(r); nonce != "" && strings.HasPrefix(ctype, "text/html") {
+		var body io.Reader = content
+		if stream != nil {
+			body = stream
+		}
+		doc, err := io.ReadAll(body)
+		if err != nil {
+			fs.fail(w, r, http.StatusInternalServerError, nil)
 			return
 		}
+		content, stream = bytes.NewReader(injectNonce(doc, nonce)), nil
+		// The content differs on every request so it has no validators
+		etag, modTime = false, time.Time{}
+	}
+	if stream != nil {
+		etag = false
+	}
 
-		// For Suppressing Directory Listing
-		fs.notFound(w, r)
+	if etag {
+		if tag, ok := fs.contentETag(name, d, content, cacheable, st); ok {
+			h.Set("ETag", tag)
+		}
+	}
+
+	if fs.cacheStatusHeader != "" {
+		h.Set(fs.cacheStatusHeader, st.cacheStatus(r))
+	}
+
+	if fs.gzip {
+		if compressible(ctype) {
+			addVary(h, "Accept-Encoding")
+			if acceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip") {
+				if tag := h.Get("ETag"); tag != "" {
+					h.Set("ETag", etagVariant(tag, "gzip"))
+				}
+				h.Set("Content-Type", ctype)
+				h.Set("Content-Encoding", "gzip")
+
+				// Ranges of the compressed stream are not supported
+				r.Header.Del("Range")
+				r.Header.Del("If-Range")
+
+				gw := &gzipWriter{ResponseWriter: w, buffer: fs.bufferTransformed, level: compressionLevel(fs.gzipLevel), noBody: r.Method == http.MethodHead}
+				defer gw.Close()
+				w = gw
+			}
+		}
+	}
+
+	if stream != nil {
+		streamContent(w, r, d, stream)
 		return
 	}
+	if fs.zeroCopyThreshold > 0 && d.Size() >= fs.zeroCopyThreshold {
+		w = zeroCopy(w)
+	}
+	http.ServeContent(w, r, d.Name(), modTime, content)
+}
 
-	// Serve the file since we know it actually exists
-	http.ServeContent(w, r, d.Name(), d.ModTime(), f)
+// peek reads up to n bytes from the start of the content and rewinds it
+func peek(content io.ReadSeeker, n int) ([]byte, error) {
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	l, err := io.ReadFull(content, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return buf[:l], nil
 }
 
-// localRedirect gives a Moved Permanently response.
-// It does not convert relative paths to absolute paths like Redirect does.
-func localRedirect(w http.ResponseWriter, r *http.Request, newPath string) {
-	if q := r.URL.RawQuery; q != "" {
-		newPath += "?" + q
+// contentETag returns the content hash ETag of the file
+func (fs *FileSystemWith404) contentETag(name string, d os.FileInfo, content io.ReadSeeker, cacheable bool, st *serverTiming) (string, bool) {
+	if cacheable {
+		if tag, ok := fs.etags.get(name, d.ModTime(), d.Size()); ok {
+			st.describe("cache", "hit")
+			return tag, true
+		}
+		st.describe("cache", "miss")
+	}
+
+	start := st.start()
+	tag, err := hashETag(content)
+	st.measure("etag", start)
+	if err != nil {
+		return "", false
 	}
-	w.Header().Set("Location", newPath)
-	w.WriteHeader(http.StatusMovedPermanently)
+	if cacheable {
+		fs.etags.put(name, d.ModTime(), d.Size(), tag)
+	}
+	return tag, true
+}
+
+// localRedirect redirects to the location without a body.
+// It does not convert relative paths to absolute paths like Redirect does.
+func localRedirect(w http.ResponseWriter, location string, code int) {
+	w.Header().Set("Location", location)
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(code)
 }
@@ -0,0 +1,277 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// notFoundBody is written by the notFound handler of the tests
+const notFoundBody = "custom not found"
+
+// testNotFound is the notFound handler of the tests
+func testNotFound(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	io.WriteString(w, notFoundBody)
+}
+
+// serve sends the request to the handler. The headers are given as pairs
+// of name and value.
+func serve(h http.Handler, method, target string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+// expect fails the test unless the response has the status and body. An
+// empty body is not checked.
+func expect(t *testing.T, w *httptest.ResponseRecorder, code int, body string) {
+	t.Helper()
+	if w.Code != code {
+		t.Errorf("status = %d, want %d", w.Code, code)
+	}
+	if body != "" && w.Body.String() != body {
+		t.Errorf("body = %q, want %q", w.Body.String(), body)
+	}
+}
+
+func TestSetNotFound(t *testing.T) {
+	fs := New(MapFS(map[string]string{"a.txt": "a"}), testNotFound)
+	expect(t, serve(fs, http.MethodGet, "/missing"), http.StatusNotFound, notFoundBody)
+
+	fs.SetNotFound(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+		io.WriteString(w, "gone")
+	})
+	expect(t, serve(fs, http.MethodGet, "/missing"), http.StatusGone, "gone")
+	expect(t, serve(fs, http.MethodGet, "/a.txt"), http.StatusOK, "a")
+}
+
+// TestSetNotFoundConcurrent swaps the handler under load, run it with
+// -race to check the swap is synchronized
+func TestSetNotFoundConcurrent(t *testing.T) {
+	fs := New(MapFS(map[string]string{"a.txt": "a"}), testNotFound)
+	handlers := []http.HandlerFunc{testNotFound, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "other")
+	}}
+
+	stop := make(chan struct{})
+	var swaps sync.WaitGroup
+	swaps.Add(1)
+	go func() {
+		defer swaps.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				fs.SetNotFound(handlers[i%len(handlers)])
+			}
+		}
+	}()
+
+	var serves sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		serves.Add(1)
+		go func() {
+			defer serves.Done()
+			for i := 0; i < 200; i++ {
+				w := serve(fs, http.MethodGet, "/missing")
+				if body := w.Body.String(); w.Code != http.StatusNotFound || (body != notFoundBody && body != "other") {
+					t.Errorf("response %d %q", w.Code, body)
+					return
+				}
+			}
+		}()
+	}
+	serves.Wait()
+	close(stop)
+	swaps.Wait()
+}
+
+func TestContentTypeResolver(t *testing.T) {
+	manifest := `{"name": "app", "padding": "` + strings.Repeat("x", 1000) + `"}`
+	var peeked int
+	fs := New(MapFS(map[string]string{
+		"manifest": manifest,
+		"page":     "<html>page</html>",
+	}), testNotFound, WithContentTypeResolver(func(name string, peek []byte) string {
+		peeked = len(peek)
+		if name == "/manifest" && strings.HasPrefix(string(peek), "{") {
+			return "application/manifest+json"
+		}
+		return ""
+	}))
+
+	// Sniffing would report text/plain
+	w := serve(fs, http.MethodGet, "/manifest")
+	expect(t, w, http.StatusOK, manifest)
+	if ct := w.Header().Get("Content-Type"); ct != "application/manifest+json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if peeked != 512 {
+		t.Errorf("resolver peeked %d bytes, want 512", peeked)
+	}
+
+	w = serve(fs, http.MethodGet, "/page")
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("unresolved Content-Type = %q, want the sniffed type", ct)
+	}
+}
+
+func TestDisableRange(t *testing.T) {
+	fs := New(MapFS(map[string]string{
+		"video/a.mp4": "0123456789",
+		"b.txt":       "0123456789",
+	}), testNotFound, WithDisableRange(func(name string) bool {
+		return strings.HasPrefix(name, "/video/")
+	}))
+
+	w := serve(fs, http.MethodGet, "/video/a.mp4", "Range", "bytes=2-4")
+	expect(t, w, http.StatusOK, "0123456789")
+	if got := w.Header().Get("Accept-Ranges"); got != "none" {
+		t.Errorf("Accept-Ranges = %q, want none", got)
+	}
+	if got := w.Header().Get("Content-Range"); got != "" {
+		t.Errorf("Content-Range = %q", got)
+	}
+
+	w = serve(fs, http.MethodGet, "/b.txt", "Range", "bytes=2-4")
+	expect(t, w, http.StatusPartialContent, "234")
+	if got := w.Header().Get("Accept-Ranges"); got != "bytes" {
+		t.Errorf("unmatched Accept-Ranges = %q, want bytes", got)
+	}
+}
+
+func TestPathRewriter(t *testing.T) {
+	fs := New(MapFS(map[string]string{"static/a.txt": "a", ".env": "secret"}), testNotFound, WithPathRewriter(func(upath string) string {
+		// The proxy encodes the path a second time
+		if unescaped, err := url.PathUnescape(upath); err == nil {
+			return unescaped
+		}
+		return upath
+	}))
+	expect(t, serve(fs, http.MethodGet, "/static%252Fa.txt"), http.StatusOK, "a")
+	expect(t, serve(fs, http.MethodGet, "/static/a.txt"), http.StatusOK, "a")
+
+	// The rewritten path is filtered
+	expect(t, serve(fs, http.MethodGet, "/%252Eenv"), http.StatusNotFound, notFoundBody)
+	expect(t, serve(fs, http.MethodGet, "/static%252F..%252F.env"), http.StatusNotFound, notFoundBody)
+}
+
+func TestRoot(t *testing.T) {
+	first := MapFS(map[string]string{"a.txt": "first"})
+	second := MapFS(map[string]string{"a.txt": "second"})
+	fs := New(first, testNotFound, WithETag(true))
+	if fs.Root() != first {
+		t.Errorf("Root is not the file system given to New")
+	}
+	tag := serve(fs, http.MethodGet, "/a.txt").Header().Get("ETag")
+
+	fs.SetRoot(second)
+	if fs.Root() != second {
+		t.Errorf("Root does not reflect SetRoot")
+	}
+	w := serve(fs, http.MethodGet, "/a.txt")
+	expect(t, w, http.StatusOK, "second")
+	if w.Header().Get("ETag") == tag {
+		t.Errorf("ETag of the previous root kept")
+	}
+}
+
+func TestRootHandler(t *testing.T) {
+	landing := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "landing")
+	})
+
+	// An index page wins over the handler
+	fs := New(MapFS(map[string]string{"index.html": "home"}), testNotFound, WithRootHandler(landing))
+	expect(t, serve(fs, http.MethodGet, "/"), http.StatusOK, "home")
+
+	// Without an index the handler answers the root only
+	files := MapFS(map[string]string{"docs/a.txt": "a", "app.html": "app"})
+	fs = New(files, testNotFound, WithRootHandler(landing), WithDirectoryListing(true), WithSPAFallback("/app.html"))
+	expect(t, serve(fs, http.MethodGet, "/"), http.StatusOK, "landing")
+	if body := serve(fs, http.MethodGet, "/docs/").Body.String(); !strings.Contains(body, `href="a.txt"`) {
+		t.Errorf("listing of /docs/ replaced by the root handler:\n%s", body)
+	}
+
+	// Without either the root is not found
+	fs = New(files, testNotFound)
+	expect(t, serve(fs, http.MethodGet, "/"), http.StatusNotFound, notFoundBody)
+}
+
+// discardWriter is a ResponseWriter dropping the response, keeping the
+// allocations of the benchmarks to the ones of serving
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// BenchmarkServeFile serves an existing file from a http.Dir using no
+// options, the fast path, and using most features that do not change the
+// response of a plain GET.
+func BenchmarkServeFile(b *testing.B) {
+	dir := b.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "css"), 0755); err != nil {
+		b.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "css", "site.css"), []byte(strings.Repeat("body{margin:0}\n", 100)), 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"vanilla", nil},
+		{"featured", []Option{
+			WithETag(true),
+			WithGzip(true),
+			WithServerTiming(true),
+			WithCSP("default-src 'self'"),
+			WithDenyGlobs("/**/*.map", "/**/*.bak"),
+			WithAllowGlobs("/css/**"),
+			WithRejectSpecialFiles(true),
+			WithRejectReservedNames(true),
+			WithHiddenDirsOnly(true),
+			WithMaxFileSize(1 << 20),
+			WithImmutablePrefix("/assets/", false),
+			WithAntiHotlink([]string{"example.org"}),
+			WithCacheStatusHeader("X-Cache"),
+			WithHeadersByContentType(map[string][]HeaderKV{"text/css": {{"X-Style", "1"}}}),
+			WithLatencyHistogram(),
+			WithMaxConcurrentServes(64, QueueServes),
+		}},
+	} {
+		fs := New(http.Dir(dir), testNotFound, bc.opts...)
+		r := httptest.NewRequest(http.MethodGet, "/css/site.css", nil)
+		if w := serve(fs, http.MethodGet, "/css/site.css"); w.Code != http.StatusOK {
+			b.Fatalf("%s: status %d", bc.name, w.Code)
+		}
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				fs.ServeHTTP(&discardWriter{header: make(http.Header)}, r)
+			}
+		})
+	}
+}
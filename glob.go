@@ -0,0 +1,67 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"path"
+	"strings"
+)
+
+// matchGlob reports if the path matches the glob pattern. Segments are
+// matched using path.Match, a "**" segment matches any number of
+// segments including none. Patterns without a leading '/' match the same
+// as with one.
+func matchGlob(pattern, upath string) bool {
+	return matchSegments(splitSegments(pattern), splitSegments(upath))
+}
+
+// splitSegments splits the path into its non-empty segments
+func splitSegments(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); !ok || err != nil {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchAnyGlob reports if the path matches any of the patterns
+func matchAnyGlob(patterns []string, upath string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, upath) {
+			return true
+		}
+	}
+	return false
+}
+
+// globDenied reports if the path is excluded by the deny or allow globs.
+// Denying takes precedence over allowing.
+func (fs *FileSystemWith404) globDenied(upath string) bool {
+	if matchAnyGlob(fs.denyGlobs, upath) {
+		return true
+	}
+	return len(fs.allowGlobs) > 0 && !matchAnyGlob(fs.allowGlobs, upath)
+}
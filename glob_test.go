@@ -0,0 +1,53 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for _, c := range []struct {
+		pattern, upath string
+		want           bool
+	}{
+		{"/**/*.map", "/app.js.map", true},
+		{"/**/*.map", "/static/js/app.js.map", true},
+		{"**/*.map", "/static/app.js.map", true},
+		{"/**/*.map", "/app.js", false},
+		{"/public/**", "/public", true},
+		{"/public/**", "/public/a/b.css", true},
+		{"/public/**", "/publicity/a.css", false},
+		{"/*/index.html", "/docs/index.html", true},
+		{"/*/index.html", "/docs/api/index.html", false},
+		{"/a/**/b/*.txt", "/a/x/y/b/c.txt", true},
+		{"/a/**/b/*.txt", "/a/b/c.txt", true},
+		{"/[", "/[", false},
+	} {
+		if got := matchGlob(c.pattern, c.upath); got != c.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", c.pattern, c.upath, got, c.want)
+		}
+	}
+}
+
+func TestGlobs(t *testing.T) {
+	files := map[string]string{
+		"public/app.js":     "app",
+		"public/app.js.map": "map",
+		"public/index.html": "home",
+		"src/app.ts":        "source",
+	}
+	fs := New(MapFS(files), testNotFound, WithDenyGlobs("**/*.map"))
+	expect(t, serve(fs, http.MethodGet, "/public/app.js.map"), http.StatusNotFound, notFoundBody)
+	expect(t, serve(fs, http.MethodGet, "/public/app.js"), http.StatusOK, "app")
+	expect(t, serve(fs, http.MethodGet, "/src/app.ts"), http.StatusOK, "source")
+
+	fs = New(MapFS(files), testNotFound, WithAllowGlobs("/public/**"), WithDenyGlobs("**/*.map"))
+	expect(t, serve(fs, http.MethodGet, "/public/app.js"), http.StatusOK, "app")
+	expect(t, serve(fs, http.MethodGet, "/public/"), http.StatusOK, "home")
+	expect(t, serve(fs, http.MethodGet, "/src/app.ts"), http.StatusNotFound, notFoundBody)
+	expect(t, serve(fs, http.MethodGet, "/public/app.js.map"), http.StatusNotFound, notFoundBody)
+}
@@ -1,3 +1,3 @@
 module github.com/boseji/filesys404
 
-go 1.16
+go 1.19
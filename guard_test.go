@@ -0,0 +1,46 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestServeGuard(t *testing.T) {
+	root := MapFS(map[string]string{
+		"index.html": "app",
+		"small.txt":  "small",
+		"large.txt":  "0123456789012345678901234567890123456789",
+	})
+	guard := func(r *http.Request, info os.FileInfo) (bool, int) {
+		if r.Header.Get("X-Key") != "secret" {
+			return false, http.StatusUnauthorized
+		}
+		return info.Size() < 32, 0
+	}
+	h := New(root, testNotFound, WithServeGuard(guard))
+
+	expect(t, serve(h, "GET", "/small.txt"), 401, "")
+	expect(t, serve(h, "GET", "/small.txt", "X-Key", "secret"), 200, "small")
+	// A code of 0 is answered with 403
+	expect(t, serve(h, "GET", "/large.txt", "X-Key", "secret"), 403, "")
+	expect(t, serve(h, "GET", "/", "X-Key", "secret"), 200, "app")
+	expect(t, serve(h, "GET", "/missing.txt"), 404, notFoundBody)
+}
+
+func TestServeGuardFallback(t *testing.T) {
+	root := MapFS(map[string]string{"index.html": "app"})
+	deny := func(r *http.Request, info os.FileInfo) (bool, int) {
+		return false, http.StatusUnauthorized
+	}
+	h := New(root, testNotFound, WithSPAFallback("/index.html"), WithServeGuard(deny))
+	for _, target := range []string{"/", "/index.html", "/some/route"} {
+		if w := serve(h, "GET", target); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", target, w.Code)
+		}
+	}
+}
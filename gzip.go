@@ -0,0 +1,173 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gzipPools keep the writers of each compression level for reuse, as a
+// gzip.Writer allocates several hundred kilobytes of state
+var gzipPools [gzip.BestCompression + 1]sync.Pool
+
+// compressionLevel returns the level in the range 1 to 9 to compress with
+func compressionLevel(level int) int {
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		// The level used for gzip.DefaultCompression
+		return 6
+	}
+	return level
+}
+
+// getGzip returns a pooled writer of the level compressing to w
+func getGzip(w io.Writer, level int) *gzip.Writer {
+	if gz, ok := gzipPools[level].Get().(*gzip.Writer); ok {
+		gz.Reset(w)
+		return gz
+	}
+	// The level is always valid
+	gz, _ := gzip.NewWriterLevel(w, level)
+	return gz
+}
+
+// compressible reports if content of the type benefits from compression
+func compressible(contentType string) bool {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(strings.ToLower(contentType))
+	switch {
+	case strings.HasPrefix(contentType, "text/"):
+		return true
+	case strings.HasSuffix(contentType, "+json"),
+		strings.HasSuffix(contentType, "+xml"):
+		return true
+	}
+	switch contentType {
+	case "application/javascript", "application/json", "application/xml",
+		"application/wasm", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// gzipWriter compresses the body of successful responses with the level.
+// Other responses like 304 Not Modified are passed through as is. With a
+// buffer size, compressed bodies up to it are sent with a Content-Length.
+type gzipWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	body        *lengthBuffer
+	buffer      int
+	level       int
+	noBody      bool
+	wroteHeader bool
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	if informational(code) {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		h := w.Header()
+		if code == http.StatusOK {
+			// Length and ranges of the identity content do not apply
+			h.Del("Content-Length")
+			h.Del("Accept-Ranges")
+			if !w.noBody && w.buffer > 0 {
+				// The status is written once the length is known
+				w.body = &lengthBuffer{ResponseWriter: w.ResponseWriter, code: code, limit: w.buffer}
+				w.gz = getGzip(w.body, w.level)
+				return
+			}
+			if !w.noBody {
+				w.gz = getGzip(w.ResponseWriter, w.level)
+			}
+		} else {
+			h.Del("Content-Encoding")
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Close flushes the remaining compressed data
+func (w *gzipWriter) Close() error {
+	if w.gz == nil {
+		return nil
+	}
+	err := w.gz.Close()
+	gzipPools[w.level].Put(w.gz)
+	w.gz = nil
+	if err != nil {
+		return err
+	}
+	if w.body != nil {
+		return w.body.flush()
+	}
+	return nil
+}
+
+// Unwrap returns the original ResponseWriter
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// lengthBuffer holds back a response body up to the limit to send it with
+// its Content-Length. Larger bodies are streamed once over the limit.
+type lengthBuffer struct {
+	http.ResponseWriter
+	code    int
+	limit   int
+	buf     bytes.Buffer
+	spilled bool
+}
+
+func (b *lengthBuffer) Write(p []byte) (int, error) {
+	if !b.spilled {
+		if b.buf.Len()+len(p) <= b.limit {
+			return b.buf.Write(p)
+		}
+		if err := b.spill(); err != nil {
+			return 0, err
+		}
+	}
+	return b.ResponseWriter.Write(p)
+}
+
+// spill writes the status and the buffered body and stops buffering
+func (b *lengthBuffer) spill() error {
+	b.spilled = true
+	b.ResponseWriter.WriteHeader(b.code)
+	_, err := b.ResponseWriter.Write(b.buf.Bytes())
+	b.buf.Reset()
+	return err
+}
+
+// flush writes the buffered body with its length if still buffering
+func (b *lengthBuffer) flush() error {
+	if b.spilled {
+		return nil
+	}
+	b.Header().Set("Content-Length", strconv.Itoa(b.buf.Len()))
+	return b.spill()
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// gunzip returns the decompressed body
+func gunzip(t *testing.T, body io.Reader) string {
+	t.Helper()
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestBufferTransformed(t *testing.T) {
+	text := strings.Repeat("compressible text ", 1000)
+	files := map[string]string{"a.txt": text}
+
+	fs := New(MapFS(files), testNotFound, WithGzip(true), WithBufferTransformed(1<<20))
+	w := serve(fs, http.MethodGet, "/a.txt", "Accept-Encoding", "gzip")
+	expect(t, w, http.StatusOK, "")
+	if got, want := w.Header().Get("Content-Length"), strconv.Itoa(w.Body.Len()); got != want {
+		t.Errorf("Content-Length = %q, want the compressed length %s", got, want)
+	}
+	if got := gunzip(t, w.Body); got != text {
+		t.Errorf("decompressed body differs")
+	}
+
+	// Output over the limit and unbuffered output is streamed
+	for _, fs := range []*FileSystemWith404{
+		New(MapFS(files), testNotFound, WithGzip(true), WithBufferTransformed(16)),
+		New(MapFS(files), testNotFound, WithGzip(true)),
+	} {
+		w := serve(fs, http.MethodGet, "/a.txt", "Accept-Encoding", "gzip")
+		if got := w.Header().Get("Content-Length"); got != "" {
+			t.Errorf("streamed Content-Length = %q", got)
+		}
+		if got := gunzip(t, w.Body); got != text {
+			t.Errorf("decompressed streamed body differs")
+		}
+	}
+
+	w = serve(fs, http.MethodHead, "/a.txt", "Accept-Encoding", "gzip")
+	if w.Body.Len() != 0 || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("HEAD body %d bytes, Content-Encoding %q", w.Body.Len(), w.Header().Get("Content-Encoding"))
+	}
+}
+
+// levelText returns text of words in random order, which compresses to
+// clearly different sizes by level
+func levelText() string {
+	words := strings.Fields("the quick brown fox jumps over a lazy dog while static files are served from disk with care")
+	rnd := rand.New(rand.NewSource(1))
+	var b strings.Builder
+	for b.Len() < 256<<10 {
+		b.WriteString(words[rnd.Intn(len(words))])
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func TestCompressionLevel(t *testing.T) {
+	text := levelText()
+	sizes := make(map[int]int)
+	for _, level := range []int{1, 6, 9, 0, 12} {
+		fs := New(MapFS(map[string]string{"a.txt": text}), testNotFound, WithGzip(true), WithCompressionLevel(level))
+		w := serve(fs, http.MethodGet, "/a.txt", "Accept-Encoding", "gzip")
+		sizes[level] = w.Body.Len()
+		if got := gunzip(t, w.Body); got != text {
+			t.Errorf("level %d: decompressed body differs", level)
+		}
+	}
+	if sizes[9] >= sizes[1] {
+		t.Errorf("level 9 gives %d bytes, not less than %d of level 1", sizes[9], sizes[1])
+	}
+	// Invalid levels use the default
+	if sizes[0] != sizes[6] || sizes[12] != sizes[6] {
+		t.Errorf("levels 0 and 12 give %d and %d bytes, want %d of level 6", sizes[0], sizes[12], sizes[6])
+	}
+}
+
+func BenchmarkGzipWriter(b *testing.B) {
+	data := []byte(levelText())
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			gz := getGzip(io.Discard, 6)
+			gz.Write(data)
+			gz.Close()
+			gzipPools[6].Put(gz)
+		}
+	})
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			gz, _ := gzip.NewWriterLevel(io.Discard, 6)
+			gz.Write(data)
+			gz.Close()
+		}
+	})
+}
+
+func BenchmarkCompressionLevel(b *testing.B) {
+	text := levelText()
+	for _, level := range []int{1, 6, 9} {
+		fs := New(MapFS(map[string]string{"a.txt": text}), testNotFound, WithGzip(true), WithCompressionLevel(level))
+		b.Run(fmt.Sprintf("level-%d", level), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(text)))
+			var size int
+			for i := 0; i < b.N; i++ {
+				size = serve(fs, http.MethodGet, "/a.txt", "Accept-Encoding", "gzip").Body.Len()
+			}
+			b.ReportMetric(float64(size), "gzip-bytes")
+		})
+	}
+}
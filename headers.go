@@ -0,0 +1,80 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// headerRule sets the headers on responses for matching paths
+type headerRule struct {
+	pattern *regexp.Regexp
+	headers http.Header
+	keys    []string
+}
+
+// parseHeadersFile parses a Netlify compatible "_headers" file. Each rule
+// starts with a path pattern on its own line followed by indented
+// "Name: Value" header lines. Lines starting with '#' are comments.
+func parseHeadersFile(r io.Reader) (interface{}, error) {
+	var rules []*headerRule
+	var cur *headerRule
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// Un-indented lines start a new rule
+		if trimmed == line {
+			re, err := splatPattern(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			cur = &headerRule{pattern: re, headers: make(http.Header)}
+			rules = append(rules, cur)
+			continue
+		}
+
+		i := strings.IndexByte(trimmed, ':')
+		if cur == nil || i <= 0 {
+			return nil, fmt.Errorf("line %d: invalid header %q", n, trimmed)
+		}
+		key := http.CanonicalHeaderKey(strings.TrimSpace(trimmed[:i]))
+		if _, ok := cur.headers[key]; !ok {
+			cur.keys = append(cur.keys, key)
+		}
+		cur.headers.Add(key, strings.TrimSpace(trimmed[i+1:]))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// applyHeaderRules sets the headers of every rule matching the path.
+// Vary values are added to the ones already on the response instead.
+func applyHeaderRules(h http.Header, rules []*headerRule, upath string) {
+	for _, rule := range rules {
+		if !rule.pattern.MatchString(upath) {
+			continue
+		}
+		for _, key := range rule.keys {
+			if key == "Vary" {
+				addVary(h, rule.headers[key]...)
+				continue
+			}
+			h[key] = append([]string(nil), rule.headers[key]...)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testHeadersFile = `# Long cache for fingerprinted assets
+/assets/*
+  Cache-Control: public, max-age=31536000
+  X-Asset: yes
+
+/blog/:post/index.html
+  X-Robots-Tag: noindex
+
+/*
+  X-Frame-Options: DENY
+  Link: </style.css>; rel=preload
+  Link: </app.js>; rel=preload
+
+/assets/legacy/*
+  Cache-Control: no-cache
+`
+
+func TestParseHeadersFile(t *testing.T) {
+	v, err := parseHeadersFile(strings.NewReader(testHeadersFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := v.([]*headerRule)
+	if len(rules) != 4 {
+		t.Fatalf("%d rules, want 4", len(rules))
+	}
+	if got := rules[2].headers["Link"]; len(got) != 2 {
+		t.Errorf("repeated header kept %q", got)
+	}
+
+	for _, bad := range []string{"  X-Orphan: yes\n", "/a\n  no colon\n"} {
+		if _, err := parseHeadersFile(strings.NewReader(bad)); err == nil {
+			t.Errorf("parsing %q succeeded", bad)
+		}
+	}
+}
+
+func TestHeadersFile(t *testing.T) {
+	fs := New(MapFS(map[string]string{
+		"_headers":              testHeadersFile,
+		"assets/app.js":         "app",
+		"assets/legacy/old.js":  "old",
+		"blog/first/index.html": "first",
+		"blog/first/photo.jpg":  "photo",
+		"index.html":            "home",
+	}), testNotFound, WithHeadersFile("/_headers"))
+
+	for target, want := range map[string]http.Header{
+		"/assets/app.js": {
+			"Cache-Control":   {"public, max-age=31536000"},
+			"X-Asset":         {"yes"},
+			"X-Frame-Options": {"DENY"},
+		},
+		"/assets/legacy/old.js": {
+			"Cache-Control": {"no-cache"},
+			"X-Asset":       {"yes"},
+		},
+		"/blog/first/index.html": {"X-Robots-Tag": {"noindex"}},
+		"/blog/first/photo.jpg":  {"X-Robots-Tag": nil},
+		"/": {
+			"Link":    {"</style.css>; rel=preload", "</app.js>; rel=preload"},
+			"X-Asset": nil,
+		},
+	} {
+		w := serve(fs, http.MethodGet, target)
+		expect(t, w, http.StatusOK, "")
+		for key, values := range want {
+			if got := w.Header().Values(key); !reflect.DeepEqual(got, values) {
+				t.Errorf("%s: %s = %q, want %q", target, key, got, values)
+			}
+		}
+	}
+	expect(t, serve(fs, http.MethodGet, "/_headers"), http.StatusNotFound, notFoundBody)
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// splitHost separates the optional port from the host
+func splitHost(hostport string) (host, port string) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		// No port present
+		return strings.Trim(hostport, "[]"), ""
+	}
+	return host, port
+}
+
+// joinHost combines the host with the optional port
+func joinHost(host, port string) string {
+	if port == "" {
+		if strings.Contains(host, ":") {
+			return "[" + host + "]"
+		}
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// firstValue returns the first entry of a comma separated header value
+func firstValue(v string) string {
+	if i := strings.IndexByte(v, ','); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v)
+}
+
+// requestOrigin returns the scheme and host the client used for the
+// request, taking the X-Forwarded-Proto and X-Forwarded-Host headers into
+// account when they are trusted.
+func (fs *FileSystemWith404) requestOrigin(r *http.Request) (scheme, host string) {
+	scheme, host = "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if fs.trustForwarded {
+		if fh := firstValue(r.Header.Get("X-Forwarded-Host")); fh != "" {
+			host = fh
+		}
+		if fp := firstValue(r.Header.Get("X-Forwarded-Proto")); fp != "" {
+			scheme = strings.ToLower(fp)
+		}
+	}
+	return scheme, host
+}
+
+// redirectCanonicalHost redirects requests for a host other than the
+// canonical host and reports if it did.
+func (fs *FileSystemWith404) redirectCanonicalHost(w http.ResponseWriter, r *http.Request) bool {
+	for _, p := range fs.canonicalExempt {
+		if r.URL.Path == p {
+			return false
+		}
+	}
+
+	scheme, reqHost := fs.requestOrigin(r)
+	name, port := splitHost(reqHost)
+	canonName, canonPort := splitHost(fs.canonicalHost)
+	if canonPort == "" {
+		// Keep the port of the request
+		canonPort = port
+	}
+	if strings.EqualFold(name, canonName) && port == canonPort {
+		return false
+	}
+
+	uri := r.RequestURI
+	if uri == "" || !strings.HasPrefix(uri, "/") {
+		uri = r.URL.RequestURI()
+	}
+	http.Redirect(w, r, scheme+"://"+joinHost(canonName, canonPort)+uri, http.StatusMovedPermanently)
+	return true
+}
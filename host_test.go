@@ -0,0 +1,42 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCanonicalHost(t *testing.T) {
+	files := map[string]string{"a.txt": "a"}
+	for _, c := range []struct {
+		canonical, target, location string
+		trust                       bool
+		headers                     []string
+	}{
+		{"example.com", "http://www.example.com/a.txt?x=1", "http://example.com/a.txt?x=1", false, nil},
+		{"example.com", "http://www.example.com:8080/a.txt", "http://example.com:8080/a.txt", false, nil},
+		{"example.com:443", "http://www.example.com:8080/a.txt", "http://example.com:443/a.txt", false, nil},
+		{"example.com", "https://www.example.com/a.txt", "https://example.com/a.txt", false, nil},
+		{"example.com", "http://[::1]/a.txt", "http://example.com/a.txt", false, nil},
+		{"example.com", "http://example.com:8080/a.txt", "https://example.com/a.txt", true, []string{"X-Forwarded-Host", "www.example.com, proxy", "X-Forwarded-Proto", "HTTPS"}},
+		{"example.com", "http://EXAMPLE.com/a.txt", "", false, nil},
+		{"example.com", "http://example.com/a.txt", "", false, []string{"X-Forwarded-Host", "www.example.com"}},
+		{"example.com", "http://www.example.com/health", "", false, nil},
+	} {
+		fs := New(MapFS(files), testNotFound, WithCanonicalHost(c.canonical, "/health"), WithTrustForwardedHeaders(c.trust))
+		w := serve(fs, http.MethodGet, c.target, c.headers...)
+		if c.location == "" {
+			if w.Code == http.StatusMovedPermanently {
+				t.Errorf("%s redirected to %s", c.target, w.Header().Get("Location"))
+			}
+			continue
+		}
+		expect(t, w, http.StatusMovedPermanently, "")
+		if got := w.Header().Get("Location"); got != c.location {
+			t.Errorf("%s: Location = %q, want %q", c.target, got, c.location)
+		}
+	}
+}
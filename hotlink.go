@@ -0,0 +1,66 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// hotlinkProtected reports if files of the type are protected from
+// hotlinking, the media types eating most bandwidth.
+func hotlinkProtected(ctype string) bool {
+	return strings.HasPrefix(ctype, "image/") ||
+		strings.HasPrefix(ctype, "video/") ||
+		strings.HasPrefix(ctype, "audio/")
+}
+
+// hotlinked reports if the request embeds the media file from a page of
+// a site not allowed to. Requests without a Referer are told apart using
+// Sec-Fetch-Site, as browsers send it even when the referrer is hidden.
+func (fs *FileSystemWith404) hotlinked(r *http.Request, name string) bool {
+	if !hotlinkProtected(mime.TypeByExtension(path.Ext(name))) {
+		return false
+	}
+
+	site := r.Header.Get("Sec-Fetch-Site")
+	if site == "same-origin" && fs.hotlinkSameOrigin {
+		return false
+	}
+
+	referer := r.Header.Get("Referer")
+	if referer == "" {
+		if site == "cross-site" || site == "same-site" {
+			return true
+		}
+		return !fs.hotlinkEmpty
+	}
+	u, err := url.Parse(referer)
+	if err != nil || u.Host == "" {
+		return true
+	}
+	host, _ := splitHost(u.Host)
+	host = strings.ToLower(host)
+
+	if fs.hotlinkSameOrigin {
+		_, origin := fs.requestOrigin(r)
+		if own, _ := splitHost(origin); strings.EqualFold(own, host) {
+			return false
+		}
+	}
+	for _, allowed := range fs.hotlinkAllowed {
+		allowed = strings.ToLower(allowed)
+		if host == allowed {
+			return false
+		}
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return false
+		}
+	}
+	return true
+}
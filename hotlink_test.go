@@ -0,0 +1,76 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAntiHotlink(t *testing.T) {
+	files := MapFS(map[string]string{
+		"logo.png":        "png",
+		"clip.mp4":        "mp4",
+		"page.html":       "html",
+		".errors/403.png": "placeholder",
+	})
+	fs := New(files, testNotFound, WithAntiHotlink([]string{"partner.org", "*.cdn.net"}))
+	for _, tc := range []struct {
+		target  string
+		headers []string
+		code    int
+	}{
+		// Allowed referers
+		{"/logo.png", []string{"Referer", "https://partner.org/page"}, http.StatusOK},
+		{"/logo.png", []string{"Referer", "https://PARTNER.org:8443/page"}, http.StatusOK},
+		{"/clip.mp4", []string{"Referer", "https://eu.cdn.net/"}, http.StatusOK},
+		{"/logo.png", []string{"Referer", "http://example.com/home"}, http.StatusOK},
+
+		// Disallowed referers
+		{"/logo.png", []string{"Referer", "https://thief.com/"}, http.StatusForbidden},
+		{"/clip.mp4", []string{"Referer", "https://partner.org.thief.com/"}, http.StatusForbidden},
+		{"/logo.png", []string{"Referer", "https://cdn.net/"}, http.StatusForbidden},
+		{"/logo.png", []string{"Referer", "not a url"}, http.StatusForbidden},
+
+		// Missing referers
+		{"/logo.png", nil, http.StatusOK},
+		{"/logo.png", []string{"Sec-Fetch-Site", "none"}, http.StatusOK},
+		{"/logo.png", []string{"Sec-Fetch-Site", "cross-site"}, http.StatusForbidden},
+		{"/logo.png", []string{"Sec-Fetch-Site", "same-site"}, http.StatusForbidden},
+
+		// Other types are not protected
+		{"/page.html", []string{"Referer", "https://thief.com/"}, http.StatusOK},
+	} {
+		w := serve(fs, http.MethodGet, tc.target, tc.headers...)
+		if w.Code != tc.code {
+			t.Errorf("%s %v: status %d, want %d", tc.target, tc.headers, w.Code, tc.code)
+		}
+	}
+
+	// Strict policy forbids missing and own referers too
+	fs = New(files, testNotFound, WithAntiHotlink([]string{"partner.org"}), WithHotlinkPolicy(false, false))
+	for _, tc := range []struct {
+		headers []string
+		code    int
+	}{
+		{nil, http.StatusForbidden},
+		{[]string{"Referer", "http://example.com/home"}, http.StatusForbidden},
+		{[]string{"Sec-Fetch-Site", "same-origin"}, http.StatusForbidden},
+		{[]string{"Referer", "https://partner.org/"}, http.StatusOK},
+	} {
+		w := serve(fs, http.MethodGet, "/logo.png", tc.headers...)
+		if w.Code != tc.code {
+			t.Errorf("strict %v: status %d, want %d", tc.headers, w.Code, tc.code)
+		}
+	}
+
+	// A placeholder is served using the error page of 403
+	fs = New(files, testNotFound, WithAntiHotlink(nil), WithErrorPages(map[int]string{http.StatusForbidden: "/.errors/403.png"}))
+	w := serve(fs, http.MethodGet, "/logo.png", "Referer", "https://thief.com/")
+	expect(t, w, http.StatusForbidden, "placeholder")
+	if got := w.Header().Get("Content-Type"); got != "image/png" {
+		t.Errorf("placeholder Content-Type %q, want image/png", got)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+)
+
+var (
+	// baseTag matches an existing <base> element in a HTML document
+	baseTag = regexp.MustCompile(`(?i)<base\b[^>]*>`)
+	// headTag matches the opening <head> element of a HTML document
+	headTag = regexp.MustCompile(`(?i)<head\b[^>]*>`)
+	// inlineTag matches the start tags of <script> and <style> elements
+	inlineTag = regexp.MustCompile(`(?i)<(script|style)\b[^>]*>`)
+	// nonceAttribute matches a nonce attribute of a start tag with its
+	// value, followed by the character ending the attribute
+	nonceAttribute = regexp.MustCompile(`(?i)\snonce(\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]*))?([\s/>])`)
+)
+
+// injectBaseHref sets the <base href> of the HTML document. An existing
+// <base> element is replaced, else one is inserted right after the opening
+// <head> element, or at the start of documents without one.
+func injectBaseHref(doc []byte, href string) []byte {
+	tag := []byte(`<base href="` + html.EscapeString(href) + `">`)
+
+	if loc := baseTag.FindIndex(doc); loc != nil {
+		return concat(doc[:loc[0]], tag, doc[loc[1]:])
+	}
+	if loc := headTag.FindIndex(doc); loc != nil {
+		return concat(doc[:loc[1]], tag, doc[loc[1]:])
+	}
+	return concat(nil, tag, doc)
+}
+
+// injectNonce adds the nonce attribute to every <script> and <style>
+// element of the HTML document. Nonces already in the document can not
+// match the one of the policy and are replaced.
+func injectNonce(doc []byte, nonce string) []byte {
+	attr := []byte(` nonce="` + html.EscapeString(nonce) + `"`)
+	return inlineTag.ReplaceAllFunc(doc, func(tag []byte) []byte {
+		tag = nonceAttribute.ReplaceAll(tag, []byte("$3"))
+		// The nonce goes right after the element name
+		name := bytes.IndexAny(tag, " \t\n\f\r/>")
+		return concat(tag[:name], attr, tag[name:])
+	})
+}
+
+// concat joins the parts into a newly allocated slice
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
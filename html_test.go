@@ -0,0 +1,116 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+func TestInjectBaseHref(t *testing.T) {
+	for doc, want := range map[string]string{
+		`<html><head><title>x</title></head></html>`: `<html><head><base href="/app/"><title>x</title></head></html>`,
+		`<HEAD lang="en"><BASE HREF="/old/"></HEAD>`: `<HEAD lang="en"><base href="/app/"></HEAD>`,
+		`<p>no head</p>`: `<base href="/app/"><p>no head</p>`,
+		`<head><base target="_blank"><script></script>`: `<head><base href="/app/"><script></script>`,
+	} {
+		if got := string(injectBaseHref([]byte(doc), "/app/")); got != want {
+			t.Errorf("injectBaseHref(%q) = %q, want %q", doc, got, want)
+		}
+	}
+	if got := string(injectBaseHref([]byte("<head>"), `/a"b/`)); got != `<head><base href="/a&#34;b/">` {
+		t.Errorf("base href not escaped: %q", got)
+	}
+}
+
+func TestInjectNonce(t *testing.T) {
+	for doc, want := range map[string]string{
+		`<script>run()</script><style>p{}</style>`:       `<script nonce="n">run()</script><style nonce="n">p{}</style>`,
+		`<SCRIPT src="a.js"></SCRIPT>`:                   `<SCRIPT nonce="n" src="a.js"></SCRIPT>`,
+		`<script nonce="old">run()</script>`:             `<script nonce="n">run()</script>`,
+		`<script async NONCE='old' src="a.js"></script>`: `<script nonce="n" async src="a.js"></script>`,
+		`<script nonce=old>run()</script>`:               `<script nonce="n">run()</script>`,
+		`<style nonce>p{}</style>`:                       `<style nonce="n">p{}</style>`,
+		`<script data-nonce="old" nonce-x="y"></script>`: `<script nonce="n" data-nonce="old" nonce-x="y"></script>`,
+		`<p>no inline code</p>`:                          `<p>no inline code</p>`,
+	} {
+		if got := string(injectNonce([]byte(doc), "n")); got != want {
+			t.Errorf("injectNonce(%q) = %q, want %q", doc, got, want)
+		}
+	}
+}
+
+func TestSPABase(t *testing.T) {
+	page := `<html><head><title>app</title></head><body><script src="main.js"></script></body></html>`
+	fs := New(MapFS(map[string]string{
+		"app/index.html": page,
+		"app/main.js":    "main",
+		"app/.env":       "secret",
+	}), testNotFound, WithSPAFallback("/app/index.html"), WithSPABase("/app/"))
+
+	want := `<html><head><base href="/app/"><title>app</title></head><body><script src="main.js"></script></body></html>`
+	for _, target := range []string{"/app/users/42", "/app/deep/nested/route/"} {
+		w := serve(fs, http.MethodGet, target)
+		expect(t, w, http.StatusOK, want)
+		if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("%s: Content-Type = %q", target, ct)
+		}
+	}
+	expect(t, serve(fs, http.MethodGet, "/app/main.js"), http.StatusOK, "main")
+	expect(t, serve(fs, http.MethodGet, "/app/.env"), http.StatusNotFound, notFoundBody)
+}
+
+func TestIndexBaseHref(t *testing.T) {
+	files := MapFS(map[string]string{
+		"docs/index.html":     `<html><head><title>Docs</title></head><body><a href="guide.html">Guide</a></body></html>`,
+		"docs/guide.html":     "guide",
+		"docs/api/index.html": `<html><head><base href="/fixed/"></head></html>`,
+		"notes/index.txt":     "plain",
+		"guide.html":          "wrong guide",
+	})
+	fs := New(files, testNotFound, WithIndexPages("index.html", "index.txt"), WithServeIndexWithoutRedirect(true))
+	link := regexp.MustCompile(`<a href="([^"]*)"`)
+	base := regexp.MustCompile(`<base href="([^"]*)">`)
+
+	// The link of the index resolves below the directory, also when the
+	// handler is mounted below a prefix
+	for target, handler := range map[string]http.Handler{
+		"/docs":      fs,
+		"/site/docs": http.StripPrefix("/site", fs),
+	} {
+		w := serve(handler, http.MethodGet, target)
+		expect(t, w, http.StatusOK, "")
+		body := w.Body.String()
+		b, a := base.FindStringSubmatch(body), link.FindStringSubmatch(body)
+		if b == nil || a == nil {
+			t.Fatalf("%s: no base href or link in %s", target, body)
+		}
+		page, _ := url.Parse(target)
+		resolved := page.ResolveReference(mustParseURL(t, b[1])).ResolveReference(mustParseURL(t, a[1]))
+		if want := target + "/guide.html"; resolved.Path != want {
+			t.Errorf("%s: link resolves to %s, want %s", target, resolved.Path, want)
+		}
+		expect(t, serve(handler, http.MethodGet, resolved.Path), http.StatusOK, "guide")
+	}
+
+	// A <base> of the page is kept, other types and requests with the
+	// slash are served unchanged
+	expect(t, serve(fs, http.MethodGet, "/docs/api"), http.StatusOK, `<html><head><base href="/fixed/"></head></html>`)
+	expect(t, serve(fs, http.MethodGet, "/notes"), http.StatusOK, "plain")
+	if body := serve(fs, http.MethodGet, "/docs/").Body.String(); base.MatchString(body) {
+		t.Errorf("base href injected with the trailing slash: %s", body)
+	}
+}
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
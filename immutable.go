@@ -0,0 +1,60 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// immutableCacheControl is sent for files below the immutable prefix
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// minFingerprint is the shortest hash segment accepted for a file
+const minFingerprint = 8
+
+// resolveImmutable resolves a path below the immutable prefix. With hash
+// stripping the first segment after the prefix is the fingerprint of the
+// file, a prefix of the hex content hash of the file it names without the
+// segment. Fingerprints of other content are not found, so long cached
+// URLs never serve a newer file.
+func (fs *FileSystemWith404) resolveImmutable(r *http.Request, urlPath string, st *serverTiming) (resolution, error) {
+	target, hash := urlPath, ""
+	if fs.immutableStrip {
+		rest := strings.TrimPrefix(urlPath, fs.immutablePrefix)
+		i := strings.IndexByte(rest, '/')
+		if i < minFingerprint {
+			return resolution{kind: resolveNotFound}, nil
+		}
+		target, hash = fs.immutablePrefix+rest[i+1:], strings.ToLower(rest[:i])
+	}
+
+	res, err := fs.resolveTarget(r, target, st)
+	if err != nil || res.kind != resolveFile {
+		return res, err
+	}
+	if hash != "" && !fs.fingerprinted(res, hash, st) {
+		res.close()
+		return resolution{kind: resolveNotFound}, nil
+	}
+	res.immutable = true
+	return res, nil
+}
+
+// fingerprinted reports if the hash is a prefix of the hex content hash of
+// the resolved file. The cached ETag holds only the first half of the hash,
+// longer fingerprints are checked against the whole of it.
+func (fs *FileSystemWith404) fingerprinted(res resolution, hash string, st *serverTiming) bool {
+	tag, ok := fs.contentETag(res.name, res.info, res.file, true, st)
+	if !ok {
+		return false
+	}
+	if tag = strings.Trim(tag, `"`); len(hash) <= len(tag) {
+		return strings.HasPrefix(tag, hash)
+	}
+	sum, err := hashContent(res.file)
+	return err == nil && strings.HasPrefix(hex.EncodeToString(sum), hash)
+}
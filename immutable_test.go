@@ -0,0 +1,69 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestImmutablePrefix(t *testing.T) {
+	files := MapFS(map[string]string{
+		"assets/app.js":   "app",
+		"assetsfoo.js":    "foo",
+		"other/style.css": "css",
+	})
+	fs := New(files, testNotFound, WithImmutablePrefix("assets", false))
+	for target, cached := range map[string]bool{
+		"/assets/app.js":   true,
+		"/assetsfoo.js":    false,
+		"/other/style.css": false,
+	} {
+		w := serve(fs, http.MethodGet, target)
+		expect(t, w, http.StatusOK, "")
+		got := w.Header().Get("Cache-Control")
+		if cached && got != immutableCacheControl {
+			t.Errorf("%s: Cache-Control %q, want %q", target, got, immutableCacheControl)
+		} else if !cached && got != "" {
+			t.Errorf("%s: Cache-Control %q outside of the prefix", target, got)
+		}
+	}
+	expect(t, serve(fs, http.MethodGet, "/assets/missing.js"), http.StatusNotFound, notFoundBody)
+	if got := serve(fs, http.MethodGet, "/assets/missing.js").Header().Get("Cache-Control"); got == immutableCacheControl {
+		t.Errorf("404 below the prefix is cached forever")
+	}
+}
+
+func TestImmutableStripHash(t *testing.T) {
+	sum := sha256.Sum256([]byte("app"))
+	hash := hex.EncodeToString(sum[:])
+	fs := New(MapFS(map[string]string{"assets/app.js": "app"}), testNotFound, WithImmutablePrefix("/assets/", true))
+
+	// Any prefix of the hash of at least 8 characters in either case
+	for _, fingerprint := range []string{hash, hash[:minFingerprint], strings.ToUpper(hash[:12])} {
+		w := serve(fs, http.MethodGet, "/assets/"+fingerprint+"/app.js")
+		expect(t, w, http.StatusOK, "app")
+		if got := w.Header().Get("Cache-Control"); got != immutableCacheControl {
+			t.Errorf("%s: Cache-Control %q, want %q", fingerprint, got, immutableCacheControl)
+		}
+	}
+
+	// A changed or too short hash is not found rather than stale
+	for _, target := range []string{
+		"/assets/0123456789abcdef/app.js",
+		"/assets/" + hash[:minFingerprint-1] + "/app.js",
+		"/assets/app.js",
+		"/assets/" + hash + "/missing.js",
+	} {
+		w := serve(fs, http.MethodGet, target)
+		expect(t, w, http.StatusNotFound, notFoundBody)
+		if got := w.Header().Get("Cache-Control"); got == immutableCacheControl {
+			t.Errorf("%s: 404 cached forever", target)
+		}
+	}
+}
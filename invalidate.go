@@ -0,0 +1,51 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"path"
+)
+
+// Invalidate drops everything cached for the file at the path, like its
+// ETag, its transformed content or the parsed rules of a headers or
+// redirects file, so the next request reads it again. Caches otherwise
+// rely on ModTime changes, this lets file system watchers make updates
+// visible instantly. It is safe to call while requests are being served.
+func (fs *FileSystemWith404) Invalidate(name string) {
+	name = path.Clean("/" + name)
+	fs.etags.delete(name)
+	fs.transforms.delete(name)
+	if fs.manifest != nil {
+		fs.manifest.reset()
+	}
+	for _, rf := range fs.ruleFiles() {
+		if rf.name == name {
+			rf.reset()
+		}
+	}
+}
+
+// InvalidateAll drops everything cached by the handler
+func (fs *FileSystemWith404) InvalidateAll() {
+	fs.etags.clear()
+	fs.transforms.clear()
+	if fs.manifest != nil {
+		fs.manifest.clear()
+	}
+	for _, rf := range fs.ruleFiles() {
+		rf.reset()
+	}
+}
+
+// ruleFiles returns the configured rule files
+func (fs *FileSystemWith404) ruleFiles() []*ruleFile {
+	var files []*ruleFile
+	for _, rf := range []*ruleFile{fs.headersFile, fs.redirectsFile} {
+		if rf != nil {
+			files = append(files, rf)
+		}
+	}
+	return files
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// changingFS serves the files of the map, which may change without their
+// ModTime changing, like on file systems of a coarse time resolution.
+type changingFS struct {
+	mu    sync.Mutex
+	files map[string]string
+}
+
+type fixedTimeFile struct {
+	http.File
+}
+
+type fixedTimeInfo struct {
+	os.FileInfo
+}
+
+func (c *changingFS) set(name, content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[name] = content
+}
+
+func (c *changingFS) Open(name string) (http.File, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, err := MapFS(c.files).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return fixedTimeFile{f}, nil
+}
+
+func (f fixedTimeFile) Stat() (os.FileInfo, error) {
+	d, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return fixedTimeInfo{d}, nil
+}
+
+func (d fixedTimeInfo) ModTime() time.Time {
+	return time.Unix(1600000000, 0)
+}
+
+func TestInvalidate(t *testing.T) {
+	root := &changingFS{files: map[string]string{
+		"a.txt":    "aaaa",
+		"_headers": "/*\n  X-Rule: one\n",
+	}}
+	fs := New(root, testNotFound, WithETag(true), WithHeadersFile("/_headers"))
+	w := serve(fs, http.MethodGet, "/a.txt")
+	tag := w.Header().Get("ETag")
+	if got := w.Header().Get("X-Rule"); got != "one" {
+		t.Fatalf("X-Rule = %q", got)
+	}
+
+	root.set("a.txt", "bbbb")
+	root.set("_headers", "/*\n  X-Rule: two\n")
+	w = serve(fs, http.MethodGet, "/a.txt")
+	if w.Header().Get("ETag") != tag || w.Header().Get("X-Rule") != "one" {
+		t.Fatalf("caches refreshed without a ModTime change")
+	}
+
+	fs.Invalidate("a.txt")
+	w = serve(fs, http.MethodGet, "/a.txt")
+	expect(t, w, http.StatusOK, "bbbb")
+	if w.Header().Get("ETag") == tag {
+		t.Errorf("ETag %s kept after Invalidate", tag)
+	}
+	if got := w.Header().Get("X-Rule"); got != "one" {
+		t.Errorf("X-Rule = %q, want the headers file untouched", got)
+	}
+
+	fs.Invalidate("/_headers")
+	if got := serve(fs, http.MethodGet, "/a.txt").Header().Get("X-Rule"); got != "two" {
+		t.Errorf("X-Rule = %q after invalidating the headers file", got)
+	}
+
+	root.set("a.txt", "cccc")
+	fs.InvalidateAll()
+	if got := serve(fs, http.MethodGet, "/a.txt").Header().Get("ETag"); got == tag || got == w.Header().Get("ETag") {
+		t.Errorf("ETag %s kept after InvalidateAll", got)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+)
+
+// ServePolicy decides how file serves exceeding the limit set using
+// WithMaxConcurrentServes are handled.
+type ServePolicy int
+
+const (
+	// QueueServes makes excess serves wait for a free slot
+	QueueServes ServePolicy = iota
+	// RejectServes answers excess serves with 503 Service Unavailable
+	RejectServes
+)
+
+// serveLimiter is a counting semaphore capping concurrent file serves
+type serveLimiter struct {
+	slots  chan struct{}
+	policy ServePolicy
+}
+
+// newServeLimiter creates a limiter allowing n concurrent serves
+func newServeLimiter(n int, policy ServePolicy) *serveLimiter {
+	return &serveLimiter{
+		slots:  make(chan struct{}, n),
+		policy: policy,
+	}
+}
+
+// acquire takes a slot, queuing or failing per the policy. It also fails
+// when the context is done while waiting.
+func (l *serveLimiter) acquire(ctx context.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.policy == RejectServes {
+		return false
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees a slot taken by acquire
+func (l *serveLimiter) release() {
+	<-l.slots
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingFS serves the files of the MapFS, the first read of a file
+// waiting for the gate to be closed. It records how many reads wait at the
+// same time.
+type blockingFS struct {
+	http.FileSystem
+	gate   chan struct{}
+	active int32
+	max    int32
+}
+
+type blockingFile struct {
+	http.File
+	fsys    *blockingFS
+	reading bool
+}
+
+func (b *blockingFS) Open(name string) (http.File, error) {
+	f, err := b.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if d, err := f.Stat(); err == nil && d.IsDir() {
+		return f, nil
+	}
+	return &blockingFile{File: f, fsys: b}, nil
+}
+
+func (f *blockingFile) Read(p []byte) (int, error) {
+	if !f.reading {
+		f.reading = true
+		active := atomic.AddInt32(&f.fsys.active, 1)
+		for {
+			max := atomic.LoadInt32(&f.fsys.max)
+			if active <= max || atomic.CompareAndSwapInt32(&f.fsys.max, max, active) {
+				break
+			}
+		}
+		<-f.fsys.gate
+		atomic.AddInt32(&f.fsys.active, -1)
+	}
+	return f.File.Read(p)
+}
+
+// waitActive waits until n reads wait for the gate
+func waitActive(t *testing.T, fsys *blockingFS, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&fsys.active) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d reads wait, want %d", atomic.LoadInt32(&fsys.active), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMaxConcurrentServesQueue(t *testing.T) {
+	fsys := &blockingFS{FileSystem: MapFS(map[string]string{"a.txt": "a"}), gate: make(chan struct{})}
+	fs := New(fsys, testNotFound, WithMaxConcurrentServes(2, QueueServes))
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 6)
+	for i := 0; i < cap(codes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(fs, http.MethodGet, "/a.txt").Code
+		}()
+	}
+	waitActive(t, fsys, 2)
+
+	// Misses do not take a slot
+	expect(t, serve(fs, http.MethodGet, "/missing"), http.StatusNotFound, notFoundBody)
+	time.Sleep(20 * time.Millisecond)
+	close(fsys.gate)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("queued serve answered %d", code)
+		}
+	}
+	if max := atomic.LoadInt32(&fsys.max); max != 2 {
+		t.Errorf("%d reads waited at the same time, want the cap of 2", max)
+	}
+}
+
+func TestMaxConcurrentServesReject(t *testing.T) {
+	fsys := &blockingFS{FileSystem: MapFS(map[string]string{"a.txt": "a"}), gate: make(chan struct{})}
+	fs := New(fsys, testNotFound, WithMaxConcurrentServes(2, RejectServes))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			expect(t, serve(fs, http.MethodGet, "/a.txt"), http.StatusOK, "a")
+		}()
+	}
+	waitActive(t, fsys, 2)
+	w := serve(fs, http.MethodGet, "/a.txt")
+	expect(t, w, http.StatusServiceUnavailable, "")
+	close(fsys.gate)
+	wg.Wait()
+	expect(t, serve(fs, http.MethodGet, "/a.txt"), http.StatusOK, "a")
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+)
+
+// WarningCategory classifies the problems reported by Lint
+type WarningCategory string
+
+// Categories of Lint warnings
+const (
+	// LintNoIndex is a directory without an index page, not listed either
+	LintNoIndex WarningCategory = "no-index"
+	// LintNotAllowed is a file outside of the WithAllowGlobs patterns
+	LintNotAllowed WarningCategory = "not-allowed"
+	// LintSourceMap is a served source map exposing the original sources
+	LintSourceMap WarningCategory = "source-map"
+	// LintEmptyFile is a zero byte file
+	LintEmptyFile WarningCategory = "empty-file"
+	// LintOversized is a file larger than WithMaxFileSize
+	LintOversized WarningCategory = "oversized"
+)
+
+// Warning is a problem of the served tree found by Lint
+type Warning struct {
+	Path     string
+	Category WarningCategory
+	Message  string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s: %s", w.Path, w.Category, w.Message)
+}
+
+// Lint scans the tree without serving anything and reports common
+// deployment problems. These are directories answering not found for lack
+// of an index page, files outside of the allow globs, exposed source
+// maps, zero byte files and files over the WithMaxFileSize limit. Hidden
+// names and configuration files are skipped. Warnings are ordered by path.
+func (fs *FileSystemWith404) Lint() []Warning {
+	var warnings []Warning
+	fs.lintDir(context.Background(), "/", nil, &warnings)
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].Path < warnings[j].Path
+	})
+	return warnings
+}
+
+// lintDir adds the warnings of the directory with the cleaned path dir
+func (fs *FileSystemWith404) lintDir(ctx context.Context, dir string, ancestors []os.FileInfo, warnings *[]Warning) {
+	warn := func(upath string, category WarningCategory, format string, args ...interface{}) {
+		*warnings = append(*warnings, Warning{Path: upath, Category: category, Message: fmt.Sprintf(format, args...)})
+	}
+
+	f, info, err := fs.open(ctx, dir, nil)
+	if err != nil {
+		return
+	}
+	if revisited(ancestors, info) {
+		f.Close()
+		return
+	}
+	ancestors = append(ancestors, info)
+	entries, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return
+	}
+
+	dirPath := dir
+	if dirPath != "/" {
+		dirPath += "/"
+	}
+	if _, idx, _, err := fs.openIndex(ctx, dir, nil); err == nil {
+		idx.Close()
+	} else if !fs.listable(dirPath) && !(dirPath == "/" && fs.rootHandler != nil) {
+		warn(dirPath, LintNoIndex, "directory has no index page and is not listed, it is not found")
+	}
+
+	for _, d := range entries {
+		upath := path.Join(dir, d.Name())
+		if fs.hidden(ctx, upath) || fs.isConfigFile(upath) {
+			continue
+		}
+		d, ok := fs.entryInfo(ctx, upath, d)
+		if !ok {
+			continue
+		}
+		if d.IsDir() {
+			fs.lintDir(ctx, upath, ancestors[:len(ancestors):len(ancestors)], warnings)
+			continue
+		}
+		if matchAnyGlob(fs.denyGlobs, upath) {
+			continue
+		}
+		if len(fs.allowGlobs) > 0 && !matchAnyGlob(fs.allowGlobs, upath) {
+			warn(upath, LintNotAllowed, "file does not match the allow globs, it is not found")
+			continue
+		}
+		if path.Ext(upath) == ".map" {
+			warn(upath, LintSourceMap, "source map exposes the original sources")
+		}
+		if d.Size() == 0 {
+			if fs.rejectEmpty {
+				warn(upath, LintEmptyFile, "zero byte file is not found")
+			} else {
+				warn(upath, LintEmptyFile, "zero byte file is served empty")
+			}
+		}
+		if fs.maxFileSize > 0 && d.Size() > fs.maxFileSize {
+			warn(upath, LintOversized, "file of %d bytes exceeds the limit of %d bytes, it is not found", d.Size(), fs.maxFileSize)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lintFindings returns the path and category of every warning
+func lintFindings(warnings []Warning) []string {
+	var got []string
+	for _, w := range warnings {
+		got = append(got, w.Path+" "+string(w.Category))
+	}
+	return got
+}
+
+func TestLint(t *testing.T) {
+	files := MapFS(map[string]string{
+		"index.html":          "home",
+		"docs/index.html":     "docs",
+		"assets/app.js":       "app",
+		"assets/app.js.map":   "{}",
+		"assets/empty.css":    "",
+		"downloads/big.zip":   strings.Repeat("z", 100),
+		"downloads/small.zip": "z",
+		"private/notes.txt":   "notes",
+		"private/keys/id.pem": "key",
+		"denied/x.bak":        "x",
+		".git/config":         "hidden",
+		"_headers":            "/*\n  X-A: b\n",
+	})
+	fs := New(files, testNotFound,
+		WithMaxFileSize(10),
+		WithAllowGlobs("/index.html", "/docs/**", "/assets/**", "/downloads/**", "/denied/**"),
+		WithDenyGlobs("/**/*.bak"),
+		WithHeadersFile("/_headers"))
+
+	want := []string{
+		"/assets/ no-index",
+		"/assets/app.js.map source-map",
+		"/assets/empty.css empty-file",
+		"/denied/ no-index",
+		"/downloads/ no-index",
+		"/downloads/big.zip oversized",
+		"/private/ no-index",
+		"/private/keys/ no-index",
+		"/private/keys/id.pem not-allowed",
+		"/private/notes.txt not-allowed",
+	}
+	warnings := fs.Lint()
+	if got := lintFindings(warnings); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint found\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	for _, w := range warnings {
+		if w.Message == "" || !strings.HasPrefix(w.String(), w.Path+": "+string(w.Category)+": ") {
+			t.Errorf("warning %q is malformed", w)
+		}
+	}
+
+	// Every warning matches the way the path is served
+	for _, w := range warnings {
+		code := serve(fs, http.MethodGet, w.Path).Code
+		switch w.Category {
+		case LintNoIndex, LintNotAllowed, LintOversized:
+			if code != http.StatusNotFound {
+				t.Errorf("%s %s is served with %d", w.Path, w.Category, code)
+			}
+		case LintSourceMap, LintEmptyFile:
+			if code != http.StatusOK {
+				t.Errorf("%s %s is served with %d", w.Path, w.Category, code)
+			}
+		}
+	}
+}
+
+func TestLintListed(t *testing.T) {
+	files := MapFS(map[string]string{"downloads/a.zip": "a", "empty.txt": ""})
+
+	// Listed directories and a root handler answer without an index
+	fs := New(files, testNotFound, WithDirectoryListing(true), WithRejectEmptyFiles(true))
+	want := []string{"/empty.txt empty-file"}
+	if got := lintFindings(fs.Lint()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint with listing found %v, want %v", got, want)
+	}
+	if w := fs.Lint()[0]; !strings.Contains(w.Message, "not found") {
+		t.Errorf("rejected empty file warning %q", w.Message)
+	}
+
+	fs = New(files, testNotFound, WithRootHandler(http.NotFoundHandler()), WithListingMatcher(func(dir string) bool {
+		return dir == "/downloads/"
+	}))
+	want = []string{"/empty.txt empty-file"}
+	if got := lintFindings(fs.Lint()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint with a matcher found %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Listing is the data a directory listing template is executed with.
+// Links are relative to the listed directory so they keep working when
+// the handler is mounted below a prefix.
+type Listing struct {
+	// Path is the request path of the directory, ending in '/'
+	Path string
+	// Parent links to the parent directory, empty for the root
+	Parent string
+	// Breadcrumbs link to every directory from the root down to the
+	// listed one, which is the last crumb
+	Breadcrumbs []Breadcrumb
+	// Entries are the servable files and directories in the directory
+	Entries []ListingEntry
+}
+
+// Breadcrumb is one directory of the path of a listing
+type Breadcrumb struct {
+	Name string
+	URL  string
+}
+
+// ListingEntry is a file or directory of a listing. Directory names and
+// URLs end in '/'.
+type ListingEntry struct {
+	Name    string
+	URL     string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+}
+
+// defaultListingTemplate is used when no custom template is configured
+var defaultListingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Index of {{.Path}}</title></head>
+<body>
+<nav>{{range $i, $c := .Breadcrumbs}}{{if $i}} / {{end}}<a href="{{$c.URL}}">{{$c.Name}}</a>{{end}}</nav>
+<ul>
+{{if .Parent}}<li><a href="{{.Parent}}">../</a></li>
+{{end}}{{range .Entries}}<li><a href="{{.URL}}">{{.Name}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+// breadcrumbs returns the crumbs of the directory path, linked relative
+// to the directory itself.
+func breadcrumbs(dir string) []Breadcrumb {
+	var names []string
+	for _, name := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	crumbs := []Breadcrumb{{Name: "/", URL: upLink(len(names))}}
+	for i, name := range names {
+		crumbs = append(crumbs, Breadcrumb{Name: name, URL: upLink(len(names) - i - 1)})
+	}
+	return crumbs
+}
+
+// upLink returns the relative link to the directory n levels up
+func upLink(n int) string {
+	if n == 0 {
+		return "./"
+	}
+	return strings.Repeat("../", n)
+}
+
+// dirsFirst orders directories before files, each by name
+func dirsFirst(a, b os.FileInfo) bool {
+	if a.IsDir() != b.IsDir() {
+		return a.IsDir()
+	}
+	return a.Name() < b.Name()
+}
+
+// listable reports if the directory without an index is to be listed
+func (fs *FileSystemWith404) listable(dir string) bool {
+	if fs.listingMatcher != nil {
+		if !strings.HasSuffix(dir, "/") {
+			dir += "/"
+		}
+		return fs.listingMatcher(dir)
+	}
+	return fs.listing
+}
+
+// listDirectory collects the listing of the opened directory
+func (fs *FileSystemWith404) listDirectory(r *http.Request, res resolution) (*Listing, error) {
+	infos, err := res.file.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := res.name
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	l := &Listing{Path: dir, Breadcrumbs: breadcrumbs(dir)}
+	if dir != "/" {
+		l.Parent = "../"
+	}
+
+	var shown []os.FileInfo
+	for _, d := range infos {
+		upath := path.Join(dir, d.Name())
+		if fs.hidden(r.Context(), upath) {
+			continue
+		}
+		d, ok := fs.entryInfo(r.Context(), upath, d)
+		if !ok || (!d.IsDir() && !fs.servable(upath, d)) {
+			continue
+		}
+		shown = append(shown, d)
+	}
+	less := fs.listingSort
+	if less == nil {
+		less = dirsFirst
+	}
+	sort.SliceStable(shown, func(i, j int) bool {
+		return less(shown[i], shown[j])
+	})
+
+	for _, d := range shown {
+		name := d.Name()
+		// Names with colons must not be read as URL schemes
+		link := (&url.URL{Path: name}).String()
+		if d.IsDir() {
+			name += "/"
+			link += "/"
+		}
+		l.Entries = append(l.Entries, ListingEntry{
+			Name:    name,
+			URL:     link,
+			IsDir:   d.IsDir(),
+			Size:    d.Size(),
+			ModTime: d.ModTime(),
+		})
+	}
+	return l, nil
+}
+
+// serveListing writes the generated listing of the resolved directory
+func (fs *FileSystemWith404) serveListing(w http.ResponseWriter, r *http.Request, res resolution) {
+	l, err := fs.listDirectory(r, res)
+	if err != nil {
+		fs.fail(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	tmpl := fs.listingTemplate
+	if tmpl == nil {
+		tmpl = defaultListingTemplate
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, l); err != nil {
+		fs.fail(w, r, http.StatusInternalServerError, nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(buf.Bytes()))
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBreadcrumbs(t *testing.T) {
+	for dir, want := range map[string][]Breadcrumb{
+		"/":      {{"/", "./"}},
+		"/docs/": {{"/", "../"}, {"docs", "./"}},
+		"/docs/api/v1/": {
+			{"/", "../../../"},
+			{"docs", "../../"},
+			{"api", "../"},
+			{"v1", "./"},
+		},
+	} {
+		if got := breadcrumbs(dir); !reflect.DeepEqual(got, want) {
+			t.Errorf("breadcrumbs(%q) = %v, want %v", dir, got, want)
+		}
+	}
+}
+
+func TestListingBreadcrumbs(t *testing.T) {
+	tmpl := template.Must(template.New("crumbs").Parse(`{{range .Breadcrumbs}}[{{.Name}} {{.URL}}]{{end}} {{.Parent}}`))
+	fs := New(MapFS(map[string]string{"docs/api/v1/a.txt": "a"}), testNotFound, WithDirectoryListing(true), WithListingTemplate(tmpl))
+	expect(t, serve(fs, http.MethodGet, "/docs/api/v1/"), http.StatusOK, "[/ ../../../][docs ../../][api ../][v1 ./] ../")
+	expect(t, serve(fs, http.MethodGet, "/"), http.StatusOK, "[/ ./] ")
+
+	// The default template links every crumb
+	fs = New(MapFS(map[string]string{"docs/api/v1/a.txt": "a"}), testNotFound, WithDirectoryListing(true))
+	body := serve(fs, http.MethodGet, "/docs/api/v1/").Body.String()
+	for _, link := range []string{`href="../../../"`, `href="../../"`, `href="../"`, `href="a.txt"`} {
+		if !strings.Contains(body, link) {
+			t.Errorf("listing misses %s:\n%s", link, body)
+		}
+	}
+}
+
+func TestListingMatcher(t *testing.T) {
+	files := MapFS(map[string]string{
+		"downloads/v1/app.zip": "zip",
+		"downloads/notes.txt":  "notes",
+		"app/main.js":          "js",
+	})
+	var dirs []string
+	fs := New(files, testNotFound, WithListingMatcher(func(dir string) bool {
+		dirs = append(dirs, dir)
+		return strings.HasPrefix(dir, "/downloads/")
+	}))
+
+	body := serve(fs, http.MethodGet, "/downloads/").Body.String()
+	for _, link := range []string{`href="v1/"`, `href="notes.txt"`} {
+		if !strings.Contains(body, link) {
+			t.Errorf("listing of /downloads/ misses %s:\n%s", link, body)
+		}
+	}
+	expect(t, serve(fs, http.MethodGet, "/downloads/v1/"), http.StatusOK, "")
+	expect(t, serve(fs, http.MethodGet, "/app/"), http.StatusNotFound, notFoundBody)
+	expect(t, serve(fs, http.MethodGet, "/"), http.StatusNotFound, notFoundBody)
+	expect(t, serve(fs, http.MethodGet, "/app/main.js"), http.StatusOK, "js")
+
+	for _, dir := range dirs {
+		if !strings.HasSuffix(dir, "/") {
+			t.Errorf("matcher got %q without the trailing slash", dir)
+		}
+	}
+
+	// The matcher wins over the global toggle
+	fs = New(files, testNotFound, WithDirectoryListing(true), WithListingMatcher(func(dir string) bool {
+		return dir == "/downloads/"
+	}))
+	expect(t, serve(fs, http.MethodGet, "/downloads/"), http.StatusOK, "")
+	expect(t, serve(fs, http.MethodGet, "/app/"), http.StatusNotFound, notFoundBody)
+}
+
+func TestListingSort(t *testing.T) {
+	tmpl := template.Must(template.New("names").Parse(`{{range .Entries}}{{.Name}} {{end}}`))
+	files := MapFS(map[string]string{
+		"b.txt":     "b",
+		"A.txt":     "A",
+		"a.txt":     "a",
+		"zdir/x":    "x",
+		"adir/x":    "x",
+		"mdir/y/z":  "z",
+		"c.txt.bak": "c",
+	})
+
+	// Directories first, then files, each by name
+	fs := New(files, testNotFound, WithDirectoryListing(true), WithListingTemplate(tmpl))
+	want := "adir/ mdir/ zdir/ A.txt a.txt b.txt c.txt.bak "
+	for i := 0; i < 3; i++ {
+		expect(t, serve(fs, http.MethodGet, "/"), http.StatusOK, want)
+	}
+
+	// A custom sort by ModTime, newest first
+	dir := t.TempDir()
+	base := time.Unix(1600000000, 0)
+	for i, name := range []string{"old.txt", "new.txt", "mid.txt"} {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := base.Add(time.Duration([]int{0, 2, 1}[i]) * time.Hour)
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs = New(http.Dir(dir), testNotFound, WithDirectoryListing(true), WithListingTemplate(tmpl),
+		WithListingSort(func(a, b os.FileInfo) bool {
+			return a.ModTime().After(b.ModTime())
+		}))
+	expect(t, serve(fs, http.MethodGet, "/"), http.StatusOK, "new.txt mid.txt old.txt ")
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bytes"
+)
+
+// magicNumbers maps the leading bytes of well-known formats to their
+// content type. The table is ordered, the first matching entry wins.
+var magicNumbers = []struct {
+	prefix []byte
+	ctype  string
+}{
+	{[]byte("\x89PNG\r\n\x1a\n"), "image/png"},
+	{[]byte("\xff\xd8\xff"), "image/jpeg"},
+	{[]byte("GIF87a"), "image/gif"},
+	{[]byte("GIF89a"), "image/gif"},
+	{[]byte("%PDF-"), "application/pdf"},
+	{[]byte("\x1f\x8b\x08"), "application/gzip"},
+	{[]byte("PK\x03\x04"), "application/zip"},
+	{[]byte("BZh"), "application/x-bzip2"},
+	{[]byte("\xfd7zXZ\x00"), "application/x-xz"},
+	{[]byte("(\xb5/\xfd"), "application/zstd"},
+	{[]byte("7z\xbc\xaf\x27\x1c"), "application/x-7z-compressed"},
+	{[]byte("\x00asm"), "application/wasm"},
+	{[]byte("wOFF"), "font/woff"},
+	{[]byte("wOF2"), "font/woff2"},
+	{[]byte("OggS"), "audio/ogg"},
+	{[]byte("fLaC"), "audio/flac"},
+	{[]byte("ID3"), "audio/mpeg"},
+	{[]byte("\x1aE\xdf\xa3"), "video/webm"},
+}
+
+// magicType returns the content type of the content by its leading
+// bytes, or "" when the format is not known.
+func magicType(head []byte) string {
+	for _, m := range magicNumbers {
+		if bytes.HasPrefix(head, m.prefix) {
+			return m.ctype
+		}
+	}
+	// RIFF containers carry the format after the size
+	if len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")) {
+		switch string(head[8:12]) {
+		case "WEBP":
+			return "image/webp"
+		case "WAVE":
+			return "audio/wav"
+		case "AVI ":
+			return "video/x-msvideo"
+		}
+	}
+	// ISO media files carry the brand after the box size
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		switch string(head[8:12]) {
+		case "avif":
+			return "image/avif"
+		case "heic", "heix":
+			return "image/heic"
+		default:
+			return "video/mp4"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMagicType(t *testing.T) {
+	for head, want := range map[string]string{
+		"\x89PNG\r\n\x1a\nrest":        "image/png",
+		"%PDF-1.7":                     "application/pdf",
+		"\x00asm\x01\x00\x00\x00":      "application/wasm",
+		"wOF2....":                     "font/woff2",
+		"RIFF\x00\x00\x00\x00WEBPVP8 ": "image/webp",
+		"RIFF\x00\x00\x00\x00WAVEfmt ": "audio/wav",
+		"RIFF\x00\x00\x00\x00other":    "",
+		"\x00\x00\x00\x1cftypavif":     "image/avif",
+		"\x00\x00\x00\x18ftypisom":     "video/mp4",
+		"RIFF":                         "",
+		"plain text":                   "",
+	} {
+		if got := magicType([]byte(head)); got != want {
+			t.Errorf("magicType(%q) = %q, want %q", head, got, want)
+		}
+	}
+}
+
+func TestMagicDetection(t *testing.T) {
+	files := map[string]string{
+		"logo":     "\x89PNG\r\n\x1a\n\x00\x00",
+		"module":   "\x00asm\x01\x00\x00\x00",
+		"font":     "wOF2\x00\x01\x00\x00",
+		"archive":  "(\xb5/\xfd\x00\x00\x00\x00",
+		"photo":    "\x00\x00\x00\x18ftypheic\x00\x00\x00\x00",
+		"notes":    "plain text",
+		"data.bin": "%PDF-1.4",
+	}
+	fs := New(MapFS(files), testNotFound, WithMagicDetection(true))
+	for target, want := range map[string]string{
+		"/logo":     "image/png",
+		"/module":   "application/wasm",
+		"/font":     "font/woff2",
+		"/archive":  "application/zstd",
+		"/photo":    "image/heic",
+		"/notes":    "text/plain; charset=utf-8",
+		"/data.bin": "application/octet-stream",
+	} {
+		if got := serve(fs, http.MethodGet, target).Header().Get("Content-Type"); got != want {
+			t.Errorf("%s: Content-Type = %q, want %q", target, got, want)
+		}
+	}
+
+	// Sniffing does not know these formats
+	fs = New(MapFS(files), testNotFound)
+	for _, target := range []string{"/archive", "/photo"} {
+		if got := serve(fs, http.MethodGet, target).Header().Get("Content-Type"); got != "application/octet-stream" {
+			t.Errorf("%s: Content-Type = %q without the option", target, got)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// manifestTTL is how long a generated asset manifest is served before
+// the tree is checked for changes again
+const manifestTTL = 10 * time.Second
+
+// integrityEntry is the cached integrity value of a file
+type integrityEntry struct {
+	modTime   time.Time
+	size      int64
+	integrity string
+}
+
+// assetManifest is the generated JSON mapping of asset paths to their
+// Subresource Integrity values. Hashes are kept per file and only
+// computed again when the ModTime or size of the file changes.
+type assetManifest struct {
+	mu     sync.Mutex
+	name   string
+	hashes map[string]integrityEntry
+	doc    []byte
+	built  time.Time
+}
+
+// reset drops the generated manifest, keeping the file hashes
+func (m *assetManifest) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.doc = nil
+}
+
+// clear drops the generated manifest and all file hashes
+func (m *assetManifest) clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.doc, m.hashes = nil, nil
+}
+
+// integrity returns the SHA-384 Subresource Integrity value of the content
+func integrity(content io.Reader) (string, error) {
+	h := sha512.New384()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	return "sha384-" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// manifestDocument returns the asset manifest, generating it again when
+// it is older than manifestTTL.
+func (fs *FileSystemWith404) manifestDocument(ctx context.Context) ([]byte, error) {
+	m := fs.manifest
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.doc != nil && fs.now().Sub(m.built) < manifestTTL {
+		return m.doc, nil
+	}
+
+	hashes := make(map[string]integrityEntry)
+	assets := make(map[string]string)
+	err := fs.walkDir(ctx, "/", nil, func(upath string, d os.FileInfo) error {
+		if strings.HasSuffix(upath, "/") || upath == m.name {
+			return nil
+		}
+		e, ok := m.hashes[upath]
+		if !ok || !e.modTime.Equal(d.ModTime()) || e.size != d.Size() {
+			f, _, err := fs.open(ctx, upath, nil)
+			if err != nil {
+				return err
+			}
+			sri, err := integrity(f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			e = integrityEntry{modTime: d.ModTime(), size: d.Size(), integrity: sri}
+		}
+		hashes[upath] = e
+		assets[upath] = e.integrity
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := json.MarshalIndent(assets, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	m.hashes, m.doc, m.built = hashes, doc, fs.now()
+	return doc, nil
+}
+
+// serveManifest writes the asset manifest
+func (fs *FileSystemWith404) serveManifest(w http.ResponseWriter, r *http.Request, notFound http.HandlerFunc) {
+	doc, err := fs.manifestDocument(r.Context())
+	if err != nil {
+		fs.fail(w, r, http.StatusInternalServerError, notFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	http.ServeContent(w, r, fs.manifest.name, time.Time{}, bytes.NewReader(doc))
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sri returns the SHA-384 Subresource Integrity value of the content
+func sri(content string) string {
+	sum := sha512.Sum384([]byte(content))
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func manifestOf(t *testing.T, fs http.Handler) map[string]string {
+	t.Helper()
+	w := serve(fs, http.MethodGet, "/integrity.json")
+	expect(t, w, http.StatusOK, "")
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("manifest Content-Type %q", got)
+	}
+	var assets map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &assets); err != nil {
+		t.Fatalf("manifest %q: %v", w.Body, err)
+	}
+	return assets
+}
+
+func TestAssetManifest(t *testing.T) {
+	root := &changingFS{files: map[string]string{
+		"index.html":     "<h1>Home</h1>",
+		"js/app.js":      "console.log(1)",
+		"css/site.css":   "body{}",
+		".env":           "secret",
+		"js/app.js.map":  "{}",
+		"integrity.json": "shadowed",
+		"empty.txt":      "",
+	}}
+	fs := New(root, testNotFound, WithAssetManifest("integrity.json"), WithDenyGlobs("/**/*.map"))
+	clock := time.Unix(1600000000, 0)
+	fs.now = func() time.Time { return clock }
+
+	want := map[string]string{
+		"/index.html":   sri("<h1>Home</h1>"),
+		"/js/app.js":    sri("console.log(1)"),
+		"/css/site.css": sri("body{}"),
+		// The well known value of the empty content
+		"/empty.txt": "sha384-OLBgp1GsljhM2TJ+sbHjaiH9txEUvgdDTAzHv2P24donTt6/529l+9Ua0vFImLlb",
+	}
+	if got := manifestOf(t, fs); !reflect.DeepEqual(got, want) {
+		t.Errorf("manifest %v, want %v", got, want)
+	}
+
+	// The manifest is reused until it expires
+	root.set("js/app.js", "console.log(22)")
+	if got := manifestOf(t, fs)["/js/app.js"]; got != want["/js/app.js"] {
+		t.Errorf("manifest generated again before expiring")
+	}
+	clock = clock.Add(manifestTTL)
+	if got := manifestOf(t, fs)["/js/app.js"]; got != sri("console.log(22)") {
+		t.Errorf("expired manifest has %s for the changed file", got)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MapFS returns an in-memory http.FileSystem holding the supplied files.
+// The keys are slash separated paths like "css/site.css" mapped to the
+// content of the file. The parent directories of every file are created as
+// well, so index pages and directory requests behave as they would on disk.
+// All entries carry the time of the call as their ModTime.
+//
+// It is mainly meant for tests, avoiding the need for temporary directories:
+//
+//	fs := filesys404.New(filesys404.MapFS(map[string]string{
+//		"index.html":    "<h1>Home</h1>",
+//		"docs/api.html": "<h1>API</h1>",
+//	}), notFound)
+func MapFS(files map[string]string) http.FileSystem {
+	m := &mapFS{modTime: time.Now(), entries: map[string]*mapEntry{"": {dir: true}}}
+	for name, content := range files {
+		name = strings.TrimPrefix(path.Clean("/"+name), "/")
+		if name == "" {
+			continue
+		}
+		if e, ok := m.entries[name]; ok {
+			// A directory wins over a file of the same name
+			if !e.dir {
+				e.data = []byte(content)
+			}
+			continue
+		}
+		m.entries[name] = &mapEntry{data: []byte(content)}
+
+		// Add the parent directories, the root always exists
+		for child := name; ; {
+			dir := strings.TrimPrefix(path.Dir("/"+child), "/")
+			parent, ok := m.entries[dir]
+			if !ok {
+				parent = &mapEntry{dir: true}
+				m.entries[dir] = parent
+			} else if !parent.dir {
+				parent.dir, parent.data = true, nil
+			}
+			parent.children = append(parent.children, path.Base(child))
+			if ok {
+				break
+			}
+			child = dir
+		}
+	}
+	for _, e := range m.entries {
+		sort.Strings(e.children)
+	}
+	return m
+}
+
+// mapFS is the file system of MapFS by slash separated path without the
+// leading slash, the root being ""
+type mapFS struct {
+	modTime time.Time
+	entries map[string]*mapEntry
+}
+
+// mapEntry is a file or a directory of the MapFS
+type mapEntry struct {
+	data     []byte
+	dir      bool
+	children []string
+}
+
+func (m *mapFS) Open(name string) (http.File, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	e, ok := m.entries[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	f := &mapFile{Reader: bytes.NewReader(e.data), info: m.info(name, e)}
+	for _, child := range e.children {
+		f.children = append(f.children, m.info(path.Join(name, child), m.entries[path.Join(name, child)]))
+	}
+	return f, nil
+}
+
+// info returns the file info of the named entry
+func (m *mapFS) info(name string, e *mapEntry) mapInfo {
+	base := path.Base("/" + name)
+	if e.dir {
+		return mapInfo{name: base, mode: os.ModeDir | 0755, modTime: m.modTime}
+	}
+	return mapInfo{name: base, size: int64(len(e.data)), mode: 0644, modTime: m.modTime}
+}
+
+// errIsDir is returned when reading a directory of the MapFS
+var errIsDir = errors.New("filesys404: is a directory")
+
+// mapFile is an opened file or directory of the MapFS
+type mapFile struct {
+	*bytes.Reader
+	info     mapInfo
+	children []os.FileInfo
+}
+
+func (f *mapFile) Read(p []byte) (int, error) {
+	if f.info.IsDir() {
+		return 0, errIsDir
+	}
+	return f.Reader.Read(p)
+}
+
+func (f *mapFile) Close() error {
+	return nil
+}
+
+// Readdir returns the next count entries of the directory like os.File
+func (f *mapFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.info.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: f.info.name, Err: errors.New("not a directory")}
+	}
+	if count <= 0 {
+		infos := f.children
+		f.children = nil
+		return infos, nil
+	}
+	if len(f.children) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(f.children) {
+		count = len(f.children)
+	}
+	infos := f.children[:count]
+	f.children = f.children[count:]
+	return infos, nil
+}
+
+func (f *mapFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+// mapInfo is the os.FileInfo of a MapFS entry
+type mapInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i mapInfo) Name() string       { return i.name }
+func (i mapInfo) Size() int64        { return i.size }
+func (i mapInfo) Mode() os.FileMode  { return i.mode }
+func (i mapInfo) ModTime() time.Time { return i.modTime }
+func (i mapInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i mapInfo) Sys() interface{}   { return nil }
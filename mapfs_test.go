@@ -0,0 +1,80 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestMapFS(t *testing.T) {
+	fsys := MapFS(map[string]string{
+		"index.html":      "home",
+		"/docs/a.html":    "a",
+		"docs/sub/b.html": "b",
+		"docs":            "shadowed by the directory",
+	})
+
+	f, err := fsys.Open("/docs/a.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, _ := f.Stat()
+	if d.Name() != "a.html" || d.Size() != 1 || d.IsDir() || d.ModTime().IsZero() {
+		t.Errorf("file info %s %d %v %v", d.Name(), d.Size(), d.IsDir(), d.ModTime())
+	}
+	if body, _ := io.ReadAll(f); string(body) != "a" {
+		t.Errorf("body = %q", body)
+	}
+	f.Close()
+
+	dir, err := fsys.Open("/docs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := dir.Readdir(1)
+	if err != nil || len(first) != 1 || first[0].Name() != "a.html" {
+		t.Fatalf("Readdir(1) = %v, %v", first, err)
+	}
+	rest, err := dir.Readdir(-1)
+	if err != nil || len(rest) != 1 || rest[0].Name() != "sub" || !rest[0].IsDir() {
+		t.Fatalf("Readdir(-1) = %v, %v", rest, err)
+	}
+	if _, err := dir.Readdir(1); err != io.EOF {
+		t.Errorf("Readdir at the end = %v, want io.EOF", err)
+	}
+
+	if _, err := fsys.Open("/missing"); !os.IsNotExist(err) {
+		t.Errorf("missing file error = %v", err)
+	}
+}
+
+func TestMapFSServed(t *testing.T) {
+	fs := New(MapFS(map[string]string{
+		"index.html":      "home",
+		"docs/index.html": "docs",
+		"docs/a.txt":      "a",
+		"blog/post.html":  "post",
+	}), testNotFound, WithDirectoryListing(true))
+	expect(t, serve(fs, http.MethodGet, "/"), http.StatusOK, "home")
+	expect(t, serve(fs, http.MethodGet, "/docs/"), http.StatusOK, "docs")
+	expect(t, serve(fs, http.MethodGet, "/docs/a.txt"), http.StatusOK, "a")
+	expect(t, serve(fs, http.MethodGet, "/missing"), http.StatusNotFound, notFoundBody)
+
+	expect(t, serve(fs, http.MethodGet, "/blog/"), http.StatusOK, "")
+
+	var got []string
+	fs.WalkServable(func(upath string, info os.FileInfo) error {
+		got = append(got, upath)
+		return nil
+	})
+	want := []string{"/", "/blog/post.html", "/docs/", "/docs/a.txt", "/docs/index.html", "/index.html"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("walked %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"strings"
+)
+
+// defaultAllow is the Allow header of handlers without WithAllowedMethods
+const defaultAllow = "GET, HEAD"
+
+// forbiddenMethod reports the methods that are never served. TRACE would
+// reflect the request, including its cookies, and CONNECT asks for a
+// tunnel no file server provides.
+func forbiddenMethod(method string) bool {
+	return method == http.MethodTrace || method == http.MethodConnect
+}
+
+// methodNotAllowed answers requests of methods that are not served with
+// 405 Method Not Allowed and a fixed body, never echoing the request.
+func (fs *FileSystemWith404) methodNotAllowed(w http.ResponseWriter, r *http.Request) bool {
+	if !forbiddenMethod(r.Method) {
+		if fs.allowedMethods == nil {
+			return false
+		}
+		for _, method := range fs.allowedMethods {
+			if method == r.Method {
+				return false
+			}
+		}
+	}
+
+	w.Header().Set("Allow", fs.allow())
+	plainError(w, http.StatusMethodNotAllowed)
+	return true
+}
+
+// allow returns the Allow header listing the methods served
+func (fs *FileSystemWith404) allow() string {
+	if fs.allowedMethods == nil {
+		return defaultAllow
+	}
+	return strings.Join(fs.allowedMethods, ", ")
+}
+
+// asteriskOptions reports if the request is "OPTIONS *", asking for the
+// capabilities of the server instead of a resource
+func asteriskOptions(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.RequestURI == "*"
+}
+
+// serverOptions answers "OPTIONS *" with 204 No Content and the methods
+// served, without looking at the file system.
+func (fs *FileSystemWith404) serverOptions(w http.ResponseWriter, r *http.Request) bool {
+	if !asteriskOptions(r) {
+		return false
+	}
+	w.Header().Set("Allow", fs.allow())
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
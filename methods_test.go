@@ -0,0 +1,85 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// countingFS counts the files opened
+type countingFS struct {
+	http.FileSystem
+	opened int
+}
+
+func (fsys *countingFS) Open(name string) (http.File, error) {
+	fsys.opened++
+	return fsys.FileSystem.Open(name)
+}
+
+func TestDefaultMethods(t *testing.T) {
+	fs := New(MapFS(map[string]string{"a.txt": "a"}), testNotFound)
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions} {
+		expect(t, serve(fs, method, "/a.txt"), http.StatusOK, "a")
+	}
+	expect(t, serve(fs, http.MethodHead, "/a.txt"), http.StatusOK, "")
+	for _, method := range []string{http.MethodTrace, http.MethodConnect} {
+		w := serve(fs, method, "/a.txt")
+		expect(t, w, http.StatusMethodNotAllowed, "")
+		if got := w.Header().Get("Allow"); got != "GET, HEAD" {
+			t.Errorf("%s: Allow = %q, want %q", method, got, "GET, HEAD")
+		}
+	}
+}
+
+func TestAllowedMethods(t *testing.T) {
+	fs := New(MapFS(map[string]string{"a.txt": "a"}), testNotFound, WithAllowedMethods("get", "POST", "TRACE"))
+	expect(t, serve(fs, http.MethodPost, "/a.txt"), http.StatusOK, "a")
+	for _, method := range []string{http.MethodHead, http.MethodTrace} {
+		w := serve(fs, method, "/a.txt")
+		expect(t, w, http.StatusMethodNotAllowed, "")
+		if got := w.Header().Get("Allow"); got != "GET, POST" {
+			t.Errorf("%s: Allow = %q, want %q", method, got, "GET, POST")
+		}
+	}
+}
+
+func TestTraceNotReflected(t *testing.T) {
+	fs := New(MapFS(map[string]string{"a.txt": "a"}), testNotFound)
+	r := httptest.NewRequest(http.MethodTrace, "/a.txt", strings.NewReader("secret body"))
+	r.Header.Set("Cookie", "session=secret")
+	w := httptest.NewRecorder()
+	fs.ServeHTTP(w, r)
+	expect(t, w, http.StatusMethodNotAllowed, "")
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("TRACE reflected the request: %q", w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+}
+
+func TestOptionsAsterisk(t *testing.T) {
+	fsys := &countingFS{FileSystem: MapFS(map[string]string{"a.txt": "a"})}
+	fs := New(fsys, testNotFound, WithAllowedMethods("GET", "HEAD", "OPTIONS"))
+	w := serve(fs, http.MethodOptions, "*")
+	expect(t, w, http.StatusNoContent, "")
+	if got := w.Header().Get("Allow"); got != "GET, HEAD, OPTIONS" {
+		t.Errorf("Allow = %q, want %q", got, "GET, HEAD, OPTIONS")
+	}
+	if fsys.opened != 0 {
+		t.Errorf("opened %d files", fsys.opened)
+	}
+
+	router := NewRouter(map[string]http.FileSystem{"/": fsys}, testNotFound)
+	w = serve(router, http.MethodOptions, "*")
+	expect(t, w, http.StatusNoContent, "")
+	if got := w.Header().Get("Allow"); got != "GET, HEAD" {
+		t.Errorf("router Allow = %q, want %q", got, "GET, HEAD")
+	}
+}
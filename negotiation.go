@@ -0,0 +1,118 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// acceptLanguage is a single weighted entry of an Accept-Language header
+type acceptLanguage struct {
+	tag string
+	q   float64
+}
+
+// parseAcceptLanguage returns the language tags of the header ordered
+// by descending quality. Entries with q=0 and the '*' wildcard are dropped.
+func parseAcceptLanguage(header string) []string {
+	var langs []acceptLanguage
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			v, err := strconv.ParseFloat(param[2:], 64)
+			if err != nil {
+				v = 0
+			}
+			q = v
+		}
+		if q <= 0 {
+			continue
+		}
+		langs = append(langs, acceptLanguage{tag: tag, q: q})
+	}
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+
+	tags := make([]string, 0, len(langs))
+	for _, l := range langs {
+		tags = append(tags, l.tag)
+	}
+	return tags
+}
+
+// acceptsEncoding reports if the Accept-Encoding header allows the encoding
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name != encoding && name != "*" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err != nil || q <= 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// languageVariant builds the variant file name for the language,
+// e.g. "/page.html" with "fr" gives "/page.fr.html".
+func languageVariant(name, lang string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + lang + ext
+}
+
+// openLanguageVariant opens the best language variant of the named file
+// accepted by the request. It returns the variant name and chosen language
+// tag, or false when no variant exists and the original file should be
+// served.
+func (fs *FileSystemWith404) openLanguageVariant(r *http.Request, name string, st *serverTiming) (string, http.File, os.FileInfo, string, bool) {
+	tried := make(map[string]bool)
+	for _, tag := range parseAcceptLanguage(r.Header.Get("Accept-Language")) {
+		candidates := []string{tag}
+		if i := strings.IndexByte(tag, '-'); i > 0 {
+			// Fall back to the primary language sub-tag
+			candidates = append(candidates, tag[:i])
+		}
+		for _, lang := range candidates {
+			if tried[lang] || strings.ContainsAny(lang, "/.") {
+				continue
+			}
+			tried[lang] = true
+			variant := languageVariant(name, lang)
+			f, d, err := fs.open(r.Context(), variant, st)
+			if err != nil {
+				continue
+			}
+			if d.IsDir() {
+				f.Close()
+				continue
+			}
+			return variant, f, d, lang, true
+		}
+	}
+	return "", nil, nil, "", false
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	for header, want := range map[string][]string{
+		"":                         {},
+		"fr":                       {"fr"},
+		"de;q=0.5, fr, en;q=0.8":   {"fr", "en", "de"},
+		"fr;q=0, de":               {"de"},
+		"*, en-GB;q=0.9":           {"en-gb"},
+		"en;q=bogus, de;q=0.1":     {"de"},
+		"pt-BR;q=0.7, es;q=0.7, *": {"pt-br", "es"},
+	} {
+		if got := parseAcceptLanguage(header); !reflect.DeepEqual(got, want) {
+			t.Errorf("parseAcceptLanguage(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestLanguageNegotiation(t *testing.T) {
+	fs := New(MapFS(map[string]string{
+		"page.html":    "default",
+		"page.fr.html": "français",
+		"page.de.html": "deutsch",
+	}), testNotFound, WithLanguageNegotiation(true))
+	for header, want := range map[string]string{
+		"":                       "default",
+		"fr":                     "français",
+		"de;q=0.9, fr;q=0.8":     "deutsch",
+		"es, de;q=0.2, fr;q=0.3": "français",
+		"fr-CA":                  "français",
+		"fr;q=0, de;q=0":         "default",
+		"es":                     "default",
+	} {
+		w := serve(fs, http.MethodGet, "/page.html", "Accept-Language", header)
+		if w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("Accept-Language %q: %d %q, want %q", header, w.Code, w.Body.String(), want)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept-Language" {
+			t.Errorf("Accept-Language %q: Vary = %q", header, got)
+		}
+	}
+	w := serve(fs, http.MethodGet, "/page.html", "Accept-Language", "de")
+	if got := w.Header().Get("Content-Language"); got != "de" {
+		t.Errorf("Content-Language = %q, want de", got)
+	}
+}
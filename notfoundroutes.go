@@ -0,0 +1,65 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// notFoundRoute is a not found handler for paths matching the glob pattern
+type notFoundRoute struct {
+	pattern  string
+	handler  http.HandlerFunc
+	literal  int
+	wildcard int
+}
+
+// newNotFoundRoutes returns the routes ordered from the most specific
+// pattern to the least specific. Patterns with more literal segments are
+// more specific, then those with more single segment wildcards, "**"
+// matching the least.
+func newNotFoundRoutes(routes map[string]http.HandlerFunc) []notFoundRoute {
+	sorted := make([]notFoundRoute, 0, len(routes))
+	for pattern, handler := range routes {
+		route := notFoundRoute{pattern: pattern, handler: handler}
+		for _, segment := range splitSegments(pattern) {
+			switch {
+			case segment == "**":
+			case strings.ContainsAny(segment, `*?[\`):
+				route.wildcard++
+			default:
+				route.literal++
+			}
+		}
+		sorted = append(sorted, route)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.literal != b.literal {
+			return a.literal > b.literal
+		}
+		if a.wildcard != b.wildcard {
+			return a.wildcard > b.wildcard
+		}
+		if len(a.pattern) != len(b.pattern) {
+			return len(a.pattern) > len(b.pattern)
+		}
+		return a.pattern < b.pattern
+	})
+	return sorted
+}
+
+// notFoundFor returns the not found handler of the most specific route
+// matching the path, else the default handler.
+func (fs *FileSystemWith404) notFoundFor(upath string, notFound http.HandlerFunc) http.HandlerFunc {
+	for _, route := range fs.notFoundRoutes {
+		if matchGlob(route.pattern, upath) {
+			return route.handler
+		}
+	}
+	return notFound
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// routeNotFound returns a not found handler writing the body
+func routeNotFound(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, body)
+	}
+}
+
+func TestNotFoundRoutes(t *testing.T) {
+	fs := New(MapFS(map[string]string{"index.html": "root"}), testNotFound, WithNotFoundRoutes(map[string]http.HandlerFunc{
+		"/api/**":     routeNotFound("api"),
+		"/api/v1/*":   routeNotFound("v1"),
+		"/docs/*.txt": routeNotFound("text"),
+	}))
+	for target, body := range map[string]string{
+		"/api/missing":    "api",
+		"/api/v1/missing": "v1",
+		"/api/v1/a/b":     "api",
+		"/docs/a.txt":     "text",
+		"/docs/a.html":    notFoundBody,
+		"/docs/../api/x":  "api",
+		"/other/missing":  notFoundBody,
+		"/api/v1/./gone":  "v1",
+	} {
+		w := serve(fs, http.MethodGet, target)
+		if w.Code != http.StatusNotFound || w.Body.String() != body {
+			t.Errorf("%s: %d %q, want 404 %q", target, w.Code, w.Body.String(), body)
+		}
+	}
+}
+
+func TestNotFoundRoutesRewritten(t *testing.T) {
+	fs := New(MapFS(map[string]string{"index.html": "root"}), testNotFound,
+		WithPathRewriter(strings.ToLower),
+		WithNotFoundRoutes(map[string]http.HandlerFunc{"/api/**": routeNotFound("api")}))
+	expect(t, serve(fs, http.MethodGet, "/API/missing"), http.StatusNotFound, "api")
+	expect(t, serve(fs, http.MethodGet, "/Other"), http.StatusNotFound, notFoundBody)
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+//go:build !unix
+
+package filesys404
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// openRegular opens the named file of the directory and returns its file
+// info, rejecting special files. Opening a named pipe blocks until a
+// writer shows up, so the check has to happen before opening the file.
+func openRegular(dir http.Dir, name string) (http.File, os.FileInfo, error) {
+	base := string(dir)
+	if base == "" {
+		base = "."
+	}
+	d, err := os.Stat(filepath.Join(base, filepath.FromSlash(path.Clean("/"+name))))
+	if err == nil && !d.IsDir() && !d.Mode().IsRegular() {
+		return nil, nil, os.ErrNotExist
+	}
+	f, err := dir.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if d, err = f.Stat(); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, d, nil
+}
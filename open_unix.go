@@ -0,0 +1,49 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+//go:build unix
+
+package filesys404
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// openRegular opens the named file of the directory like http.Dir does
+// and returns its file info, but rejects special files. Opening without
+// blocking keeps named pipes from waiting for a writer, so the type is
+// checked on the opened file instead of by an extra Stat of the path.
+func openRegular(dir http.Dir, name string) (http.File, os.FileInfo, error) {
+	base := string(dir)
+	if base == "" {
+		base = "."
+	}
+	// Names are mostly clean already, sparing the copy
+	if !strings.HasPrefix(name, "/") || path.Clean(name) != name {
+		name = path.Clean("/" + name)
+	}
+	f, err := os.OpenFile(filepath.Join(base, filepath.FromSlash(name)), os.O_RDONLY|syscall.O_NONBLOCK|syscall.O_NOCTTY, 0)
+	if err != nil {
+		// A file named as a directory is missing, as for http.Dir
+		if errors.Is(err, syscall.ENOTDIR) {
+			return nil, nil, os.ErrNotExist
+		}
+		return nil, nil, err
+	}
+	d, err := f.Stat()
+	if err == nil && !d.IsDir() && !d.Mode().IsRegular() {
+		err = os.ErrNotExist
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, d, nil
+}
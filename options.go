@@ -0,0 +1,744 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Option configures the optional behaviour of a FileSystemWith404
+type Option func(fs *FileSystemWith404)
+
+// WithIndexPages sets the ordered list of index file names tried when
+// a directory is requested. The default is "index.html".
+func WithIndexPages(names ...string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.indexPages = append([]string(nil), names...)
+	}
+}
+
+// WithIndexResolver sets a hook returning the ordered index candidates for
+// the requested directory. The directory is supplied as a cleaned URL path
+// with a trailing '/', e.g. "/" or "/blog/". By default the list configured
+// through WithIndexPages is used for every directory.
+func WithIndexResolver(resolver func(dir string) []string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.indexResolver = resolver
+	}
+}
+
+// WithServerTiming enables the Server-Timing response header carrying the
+// time spent opening and stat-ing files for each request. It is meant for
+// debugging and is disabled by default.
+func WithServerTiming(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.serverTiming = enable
+	}
+}
+
+// WithLanguageNegotiation enables serving language variants of files based
+// on the Accept-Language header of the request. A request for "page.html"
+// with "Accept-Language: fr" is served "page.fr.html" when it exists, else
+// the original file. Variants are tried in the order of their q-values.
+func WithLanguageNegotiation(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.languageNegotiation = enable
+	}
+}
+
+// WithSPAFallback serves the named page, e.g. "/index.html", in place of
+// the notFound handler for requests that do not resolve to a file. This
+// lets client side routing of Single Page Applications handle such paths.
+// Hidden files are still routed to the notFound handler.
+func WithSPAFallback(page string) Option {
+	return func(fs *FileSystemWith404) {
+		if page != "" && !strings.HasPrefix(page, "/") {
+			page = "/" + page
+		}
+		fs.spaFallback = page
+	}
+}
+
+// WithSPABase sets the base URL of a Single Page Application mounted under
+// a sub-path, e.g. "/app/". The fallback page configured using
+// WithSPAFallback is rewritten to carry a <base href> element pointing at
+// it, so relative asset paths and client routing keep working for nested
+// routes. An existing <base> element of the page is replaced, otherwise
+// one is inserted right after the opening <head> element.
+func WithSPABase(base string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.spaBase = base
+	}
+}
+
+// WithETag enables strong ETag validators computed from a hash of the
+// file content. The hashes are cached per file and recomputed when its
+// ModTime or size changes. The ETag is honored by conditional requests
+// using If-None-Match, If-Match and If-Range.
+//
+// If-Match uses the strong comparison, so a request whose If-Match does
+// not list the ETag of the representation being served, including weak
+// ETags and the ETag of another content encoding, is answered with 412
+// Precondition Failed instead of the file. Without WithETag only
+// "If-Match: *" can succeed.
+func WithETag(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.etag = enable
+	}
+}
+
+// WithGzip enables on-the-fly gzip compression of text like content for
+// clients accepting it. Compressed responses carry "Vary: Accept-Encoding"
+// and an ETag suffixed with "-gzip", so caches never confuse them with the
+// identity encoded variant. Range requests are only honored for clients
+// not accepting gzip, as ranges of the compressed stream are not supported.
+func WithGzip(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.gzip = enable
+	}
+}
+
+// WithServeIndexWithoutRedirect serves the index page of a directory
+// requested without a trailing '/', e.g. "/docs", directly instead of first
+// redirecting to "/docs/". Directories without an index page are still
+// redirected. HTML index pages without a <base> element get one pointing
+// at the directory, so their relative links still resolve against "/docs/".
+func WithServeIndexWithoutRedirect(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.indexWithoutRedirect = enable
+	}
+}
+
+// WithLatencyHistogram records the serve duration of every request into a
+// histogram available through Stats. The bounds are the upper bounds of
+// the buckets, DefaultLatencyBuckets are used when none are given. An
+// overflow bucket collecting slower requests is always added.
+func WithLatencyHistogram(bounds ...time.Duration) Option {
+	return func(fs *FileSystemWith404) {
+		if len(bounds) == 0 {
+			bounds = DefaultLatencyBuckets
+		}
+		fs.latency = newLatencyHistogram(bounds)
+	}
+}
+
+// WithDotFileHandler sets the handler invoked for requests blocked because
+// they contain hidden dot file or directory names, e.g. to answer probes
+// for ".git" or ".env" with a 403 Forbidden. The notFound handler is used
+// by default.
+func WithDotFileHandler(handler http.HandlerFunc) Option {
+	return func(fs *FileSystemWith404) {
+		fs.dotFileHandler = handler
+	}
+}
+
+// WithContentTypeResolver sets a hook determining the Content-Type of
+// served files. It receives the file path and up to the first 512 bytes of
+// its content. A non-empty result overrides both the extension and the
+// content sniffing based detection of http.ServeContent.
+func WithContentTypeResolver(resolver func(name string, peek []byte) string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.contentTypeResolver = resolver
+	}
+}
+
+// WithMaxConcurrentServes caps the number of files being served at the same
+// time, protecting slow backing file systems from thundering herds. Serves
+// exceeding the cap are queued or answered with 503 Service Unavailable
+// depending on the policy. Not found responses and redirects do not count
+// against the cap. A limit of zero or less disables it.
+func WithMaxConcurrentServes(n int, policy ServePolicy) Option {
+	return func(fs *FileSystemWith404) {
+		if n <= 0 {
+			fs.limiter = nil
+			return
+		}
+		fs.limiter = newServeLimiter(n, policy)
+	}
+}
+
+// WithDisableRange turns off Range support for the files whose path is
+// matched, forcing full downloads. The Range header of such requests is
+// ignored and responses carry "Accept-Ranges: none". This suits content
+// that is transformed on the fly where byte ranges are meaningless.
+func WithDisableRange(matcher func(name string) bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.disableRange = matcher
+	}
+}
+
+// WithCSP sets the Content-Security-Policy header of all responses
+func WithCSP(policy string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.csp = policy
+	}
+}
+
+// WithCSPNonce generates a cryptographically random nonce for every request
+// and adds it to the policies set using WithCSP and WithCSPReportOnly, as a
+// 'nonce-' source of their script-src and style-src directives, and of
+// default-src when either is missing. Every <script> and <style> element
+// of served HTML files gets a matching nonce attribute so inline code
+// passes the policy. The nonce is also available to the notFound handler
+// using CSPNonce.
+func WithCSPNonce(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.cspNonce = enable
+	}
+}
+
+// WithRejectEmptyFiles routes requests for zero byte files to the notFound
+// handler, as empty assets usually point to a broken build. By default
+// such files are served normally.
+func WithRejectEmptyFiles(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.rejectEmpty = enable
+	}
+}
+
+// WithHeadersFile sets custom response headers per path using a Netlify
+// compatible "_headers" file stored at the named path of the root:
+//
+//	# Long cache for fingerprinted assets
+//	/assets/*
+//	  Cache-Control: public, max-age=31536000
+//	/blog/:post/index.html
+//	  X-Robots-Tag: noindex
+//
+// A '*' in the path pattern matches any characters and a ":name" segment
+// matches one path segment. Patterns are matched against the request path.
+// All matching rules are applied in order, a header of a later rule
+// replacing the same header of an earlier one. The file is parsed at
+// startup and again whenever its ModTime changes. It is never served.
+func WithHeadersFile(name string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.headersFile = newRuleFile(name, parseHeadersFile)
+	}
+}
+
+// WithRedirectsFile applies redirect and rewrite rules from a Netlify
+// compatible "_redirects" file stored at the named path of the root:
+//
+//	# from          to                status
+//	/old/page.html  /new/page.html
+//	/news/*         /blog/:splat      302
+//	/user/:id       /profile.html     200
+//	/*              /index.html       200
+//
+// The status defaults to 301. The statuses 301, 302, 307 and 308 redirect,
+// while 200 rewrites the request to the target path internally. A '*' in
+// the pattern is available in the target as ":splat", ":name" segments
+// under their name.
+// The first matching rule applies, before the file system is consulted.
+// Like on Netlify rules are skipped for requests resolving to an existing
+// file, which lets "/* /index.html 200" act as a SPA fallback, unless the
+// status is suffixed with '!'. The query string is kept for redirects. The
+// file is parsed at startup and again whenever its ModTime changes. It is
+// never served.
+func WithRedirectsFile(name string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.redirectsFile = newRuleFile(name, parseRedirectsFile)
+	}
+}
+
+// WithCanonicalHost redirects requests for any other host, e.g. "www."
+// to the apex domain, to the canonical host using 301 Moved Permanently.
+// The scheme, path and query are preserved. When the canonical host has
+// no port the port of the request is kept. Requests for the exempt paths,
+// like health checks, are never redirected.
+func WithCanonicalHost(host string, exempt ...string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.canonicalHost = host
+		fs.canonicalExempt = append([]string(nil), exempt...)
+	}
+}
+
+// WithTrustForwardedHeaders uses the X-Forwarded-Host and X-Forwarded-Proto
+// headers set by a reverse proxy to find the host and scheme the client
+// used. Only enable it behind a proxy that sets or strips these headers.
+func WithTrustForwardedHeaders(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.trustForwarded = enable
+	}
+}
+
+// WithDirectoryDefault sets a hook naming the entry document of the
+// requested directory, given like for WithIndexResolver. A non-empty name
+// is served in place of the index pages, else these are used as usual.
+func WithDirectoryDefault(fn func(dir string) string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.directoryDefault = fn
+	}
+}
+
+// WithDirectoryDefaultFile lets directories name their own entry document
+// in a convention file, e.g. ".serveindex", holding the file name on its
+// first line. Directories without the file use the index pages. The
+// convention files are never served.
+func WithDirectoryDefaultFile(name string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.directoryDefaultFile = name
+	}
+}
+
+// WithErrorPages serves pre-rendered pages from the root for the error
+// responses of the handler, mapping status codes like 403, 404, 500 or 503
+// to the path of the page, e.g. "/errors/503.html". The pages are served
+// with their status even when hidden. A page for 404 takes precedence over
+// the notFound handler. When a page is missing the notFound handler or a
+// plain text message is used.
+func WithErrorPages(pages map[int]string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.errorPages = make(map[int]string, len(pages))
+		for code, page := range pages {
+			fs.errorPages[code] = path.Clean("/" + page)
+		}
+	}
+}
+
+// WithTryFiles resolves requests like the try_files directive of nginx,
+// replacing the default resolution. In each pattern "$uri" stands for the
+// request path. All but the last pattern are tried in order and the first
+// one naming an existing file is served, patterns ending in '/' like
+// "$uri/" name a directory whose index page is served. The last pattern is
+// the fallback, either a status like "=404" or the path of a file to serve.
+// Some common setups:
+//
+//	WithTryFiles("$uri", "$uri/", "/index.html")     // SPA fallback
+//	WithTryFiles("$uri", "$uri.html", "$uri/", "=404") // extensionless pages
+//
+// Hidden files are never tried, the fallback page is served even if hidden.
+func WithTryFiles(patterns ...string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.tryFiles = append([]string(nil), patterns...)
+	}
+}
+
+// WithHiddenDirsOnly limits the hidden file filter to directories. Files
+// within hidden directories like "/.git/config" stay blocked, but a hidden
+// file itself like "/.htaccess" is served. Telling both apart costs an
+// additional Stat for requests ending in a hidden name.
+func WithHiddenDirsOnly(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.hiddenDirsOnly = enable
+	}
+}
+
+// WithPathRewriter rewrites the request path before any other processing,
+// an escape hatch for proxies mangling paths like double encoding them.
+// The rewritten path is the one filtered for hidden files and resolved.
+func WithPathRewriter(rewrite func(upath string) string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.pathRewriter = rewrite
+	}
+}
+
+// WithRejectSpecialFiles treats named pipes, sockets, devices and other
+// special files as missing. Reading them could block the request forever
+// or expose system state. Enabled by default.
+func WithRejectSpecialFiles(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.rejectSpecial = enable
+	}
+}
+
+// WithReadSeekerTransform rewrites the content of files before serving,
+// like substituting environment values in configuration JSON or rewriting
+// asset URLs. The returned content must be seekable so Range requests
+// keep working, else disable them using WithDisableRange. Results up to
+// 256KiB are cached until the ModTime or size of the file changes.
+func WithReadSeekerTransform(transform func(name string, rs io.ReadSeeker) (io.ReadSeeker, error)) Option {
+	return func(fs *FileSystemWith404) {
+		fs.transform = transform
+	}
+}
+
+// WithDirectoryListing lists the content of directories without an index
+// page instead of answering them as not found. Hidden names and files
+// which are not servable are left out of the listing.
+func WithDirectoryListing(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.listing = enable
+	}
+}
+
+// WithListingTemplate replaces the HTML template of directory listings.
+// It is executed with a *Listing, which also carries the breadcrumb trail
+// and the parent directory link of the default listing.
+func WithListingTemplate(tmpl *template.Template) Option {
+	return func(fs *FileSystemWith404) {
+		fs.listingTemplate = tmpl
+	}
+}
+
+// WithRedirectIndexToDir redirects requests naming an index page, like
+// "/sub/index.html", to their directory "/sub/" with a Moved Permanently
+// response, the same as http.FileServer does. Redirect rules are applied
+// first.
+func WithRedirectIndexToDir(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.redirectIndex = enable
+	}
+}
+
+// WithMagicDetection detects the content type of files without extension
+// from the leading bytes of well-known formats like PNG, PDF or gzip.
+// Formats not in the table are left to the sniffing of http.ServeContent.
+func WithMagicDetection(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.magicDetection = enable
+	}
+}
+
+// WithRejectReservedNames treats paths as missing when a segment is a
+// Windows device name like "CON" or "nul.txt", ends in a dot or space, or
+// contains characters Windows does not allow in names. Such names can
+// resolve to other files or devices depending on where the site is
+// deployed.
+func WithRejectReservedNames(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.rejectReserved = enable
+	}
+}
+
+// WithRobotsFallback serves the content as "/robots.txt" when the root
+// has no such file. Some crawlers read a missing robots.txt as permission
+// to crawl everything. A real robots.txt always takes precedence.
+func WithRobotsFallback(content string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.robotsFallback = content
+	}
+}
+
+// WithBufferTransformed buffers content whose length is only known after
+// transforming it, like gzip compressed files, to send it with an exact
+// Content-Length instead of chunked. Output larger than maxSize bytes is
+// streamed as before.
+func WithBufferTransformed(maxSize int) Option {
+	return func(fs *FileSystemWith404) {
+		fs.bufferTransformed = maxSize
+	}
+}
+
+// WithDenyGlobs treats paths matching any of the glob patterns as missing,
+// like "**/*.map" to hide source maps in production. Segments are matched
+// using path.Match and a "**" segment matches any number of segments. The
+// patterns are matched against the cleaned request path before resolving
+// it and take precedence over WithAllowGlobs.
+func WithDenyGlobs(patterns ...string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.denyGlobs = append(fs.denyGlobs, patterns...)
+	}
+}
+
+// WithAllowGlobs restricts the servable paths to the ones matching any of
+// the glob patterns, like "/public/**". Other paths are treated as missing.
+// The patterns work the same as for WithDenyGlobs.
+func WithAllowGlobs(patterns ...string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.allowGlobs = append(fs.allowGlobs, patterns...)
+	}
+}
+
+// WithListingMatcher lists only the directories the matcher reports true
+// for, like a browsable "/downloads/" area in an otherwise unlisted site.
+// The matcher receives the directory path ending in '/' and replaces the
+// WithDirectoryListing toggle.
+func WithListingMatcher(match func(dir string) bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.listingMatcher = match
+	}
+}
+
+// WithThrottle limits the speed file bodies are written with to the rate
+// in bytes per second for each response, e.g. to simulate slow clients
+// or to spare upstream bandwidth. Error pages and redirects are not
+// throttled. Requests whose context is done stop promptly.
+func WithThrottle(bytesPerSec int64) Option {
+	return func(fs *FileSystemWith404) {
+		fs.throttle = bytesPerSec
+	}
+}
+
+// WithListingSort orders the entries of directory listings, less reports
+// if a is listed before b. By default directories are listed first, then
+// files, each ordered by name.
+func WithListingSort(less func(a, b os.FileInfo) bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.listingSort = less
+	}
+}
+
+// WithImmutablePrefix serves files below the path prefix, like
+// "/assets/", to be cached forever using
+// "Cache-Control: public, max-age=31536000, immutable". With stripHash
+// the first segment below the prefix is a fingerprint of the file and not
+// part of its path, "/assets/3f2a9c1b/app.js" serves "/assets/app.js".
+// The fingerprint must be a prefix, at least 8 characters long, of the
+// hex SHA-256 hash of the content, else the request is not found.
+func WithImmutablePrefix(prefix string, stripHash bool) Option {
+	return func(fs *FileSystemWith404) {
+		prefix = path.Clean("/" + prefix)
+		if prefix != "/" {
+			prefix += "/"
+		}
+		fs.immutablePrefix = prefix
+		fs.immutableStrip = stripHash
+	}
+}
+
+// WithAntiHotlink forbids embedding images, audio and video in pages of
+// other sites. The Referer host must be one of the allowed hosts, where
+// "*.example.com" allows all subdomains, or the host of the request.
+// Forbidden requests get a 403 Forbidden response, configure an error
+// page for it using WithErrorPages to serve a placeholder.
+func WithAntiHotlink(allowedReferers []string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.hotlinkAllowed = append([]string{}, allowedReferers...)
+	}
+}
+
+// WithHotlinkPolicy sets if requests without a Referer and requests from
+// the own origin pass the hotlinking protection. Both are allowed by
+// default. Requests without a Referer that browsers mark as cross-site
+// using Sec-Fetch-Site are always forbidden.
+func WithHotlinkPolicy(allowEmpty, allowSameOrigin bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.hotlinkEmpty = allowEmpty
+		fs.hotlinkSameOrigin = allowSameOrigin
+	}
+}
+
+// WithRootHandler answers requests for the root "/" using the handler
+// when the root has no index page, like to render a landing page. It
+// takes precedence over directory listings and the SPA fallback.
+func WithRootHandler(h http.Handler) Option {
+	return func(fs *FileSystemWith404) {
+		fs.rootHandler = h
+	}
+}
+
+// WithPreloadLinks adds Link headers to the files served, like
+// "</app.css>; rel=preload; as=style" for pages using the stylesheet.
+// The function returns the header values for the named file.
+func WithPreloadLinks(links func(name string) []string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.preloadLinks = links
+	}
+}
+
+// WithEarlyHints sends the preload links of WithPreloadLinks ahead in a
+// 103 Early Hints response before the file, so clients can start loading
+// them early. It needs a server writing interim responses, like
+// http.Server since Go 1.19, other ResponseWriters like
+// httptest.ResponseRecorder get no 103. Middleware wrapping the writer
+// must provide an Unwrap method returning it, as for
+// http.ResponseController. Links are still sent with the final response
+// for clients ignoring early hints.
+func WithEarlyHints(enable bool) Option {
+	return func(fs *FileSystemWith404) {
+		fs.earlyHints = enable
+	}
+}
+
+// WithCacheBustParam makes requests with the query parameter set to a
+// true value, like "?nocache=1", bypass the in-memory caches of ETags and
+// transformed content and read the file afresh. Such responses carry
+// "Cache-Control: no-store", letting developers check deployed content
+// without clearing caches.
+func WithCacheBustParam(name string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.cacheBustParam = name
+	}
+}
+
+// WithBufferNonSeekable reads files of file systems unable to seek them
+// into memory when up to maxSize bytes, so they are served with Range and
+// conditional request support. Larger files, and all of them without the
+// option, are streamed with a 200 OK response. Streams hold a slot of
+// WithMaxConcurrentServes and get the same headers, throttling and CSP
+// nonces as other files.
+func WithBufferNonSeekable(maxSize int) Option {
+	return func(fs *FileSystemWith404) {
+		fs.bufferNonSeekable = maxSize
+	}
+}
+
+// WithAliases redirects old paths to new ones with a Moved Permanently
+// response, before looking for files, for URL migrations without a
+// redirects file. Keys ending in '/' are prefix aliases moving everything
+// below them, "/old/" to "/new/" redirects "/old/a/b" to "/new/a/b".
+// Exact aliases take precedence, then the longest prefix. Aliases of
+// aliases are followed to their final target, aliases leading into a
+// cycle are ignored and reported by NewStrict. So are prefix aliases moving
+// below themselves, like "/docs/" to "/docs/v2/".
+func WithAliases(aliases map[string]string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.aliases = newAliases(aliases)
+	}
+}
+
+// WithTracer starts a span for every request using the tracer. Spans carry
+// the resolution, resolved path, response status, ETag cache outcome and
+// serve duration, see the Trace attribute keys, and record resolution
+// errors.
+func WithTracer(tracer Tracer) Option {
+	return func(fs *FileSystemWith404) {
+		fs.tracer = tracer
+	}
+}
+
+// WithAssetManifest serves a JSON manifest at the path, like
+// "/integrity.json", mapping the path of every servable file to its
+// SHA-384 Subresource Integrity value. The manifest is generated on
+// request and reused for a few seconds, file hashes are only computed
+// again when the ModTime or size of a file changes.
+func WithAssetManifest(name string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.manifest = &assetManifest{name: path.Clean("/" + name)}
+	}
+}
+
+// WithMaxFileSize treats files larger than size bytes as missing, keeping
+// accidentally deployed archives or dumps from being served. Lint reports
+// such files.
+func WithMaxFileSize(size int64) Option {
+	return func(fs *FileSystemWith404) {
+		fs.maxFileSize = size
+	}
+}
+
+// WithCompressionLevel sets the level WithGzip compresses with, from 1 for
+// the fastest to 9 for the smallest responses. Other levels use the
+// default level 6, NewStrict reports them.
+func WithCompressionLevel(level int) Option {
+	return func(fs *FileSystemWith404) {
+		fs.gzipLevel = level
+	}
+}
+
+// WithServeGuard is called with every resolved file, including index
+// pages and the SPA fallback page, just before serving it. Returning false
+// answers with the status code instead, like 401 for a failed
+// authorization check, or 403 for a code of 0. The file info allows
+// deciding on the size or modification time of the file.
+func WithServeGuard(guard func(r *http.Request, info os.FileInfo) (bool, int)) Option {
+	return func(fs *FileSystemWith404) {
+		fs.serveGuard = guard
+	}
+}
+
+// WithAllowedMethods restricts the request methods served, e.g. to
+// "GET" and "HEAD". Other methods are answered with 405 Method Not Allowed
+// listing the allowed ones, as is "OPTIONS *". TRACE and CONNECT are
+// always rejected, even without this option and when listed.
+//
+// A http.Server answers "OPTIONS *" itself before calling any handler, so
+// only handlers called directly or by other servers see it.
+func WithAllowedMethods(methods ...string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.allowedMethods = make([]string, 0, len(methods))
+		for _, method := range methods {
+			if method = strings.ToUpper(method); !forbiddenMethod(method) {
+				fs.allowedMethods = append(fs.allowedMethods, method)
+			}
+		}
+	}
+}
+
+// WithNotFoundDelay delays not found responses by d, slowing down scanners
+// brute forcing paths while regular visitors rarely notice. The delay holds
+// no lock and ends early when the client disconnects.
+func WithNotFoundDelay(d time.Duration) Option {
+	return func(fs *FileSystemWith404) {
+		fs.notFoundDelay = d
+	}
+}
+
+// WithNotFoundJitter adds a random duration of up to jitter to the delay
+// of WithNotFoundDelay, so the delay cannot be told apart from a slow
+// server.
+func WithNotFoundJitter(jitter time.Duration) Option {
+	return func(fs *FileSystemWith404) {
+		fs.notFoundJitter = jitter
+	}
+}
+
+// WithNotFoundRoutes answers misses of paths matching a glob pattern with
+// the pattern's handler instead of the default not found handler, e.g. a
+// JSON error for "/api/**" next to the HTML page of the site. Patterns
+// match like WithDenyGlobs against the cleaned path after WithPathRewriter,
+// and the most specific matching pattern wins: the one with the most
+// literal segments, then the most wildcard segments.
+func WithNotFoundRoutes(routes map[string]http.HandlerFunc) Option {
+	return func(fs *FileSystemWith404) {
+		fs.notFoundRoutes = newNotFoundRoutes(routes)
+	}
+}
+
+// WithZeroCopyThreshold sends files of at least size bytes using the
+// io.ReaderFrom of the server's ResponseWriter, even behind the writers of
+// WithServerTiming or WithTracer. For files of a http.Dir this lets the
+// kernel copy them to the connection. Compressed and throttled responses,
+// and ResponseWriters without ReaderFrom, use the standard copy.
+func WithZeroCopyThreshold(size int64) Option {
+	return func(fs *FileSystemWith404) {
+		fs.zeroCopyThreshold = size
+	}
+}
+
+// WithCacheStatusHeader sets the named header, e.g. "X-Cache", on served
+// files to HIT when the ETag or transform cache had the content, MISS when
+// it was computed and cached, and BYPASS when served without the caches.
+func WithCacheStatusHeader(name string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.cacheStatusHeader = name
+	}
+}
+
+// WithCSPReportOnly sets the Content-Security-Policy-Report-Only header of
+// all responses, reporting violations of the policy without enforcing it.
+// It allows trying a policy before switching it to WithCSP, next to the
+// enforced one or on its own. WithCSPNonce adds the same nonce to both.
+func WithCSPReportOnly(policy string) Option {
+	return func(fs *FileSystemWith404) {
+		fs.cspReportOnly = policy
+	}
+}
+
+// WithCanonicalURLPolicy serves every page at the one URL of the policy
+// and permanently redirects the other shapes to it, so the same page is
+// never reachable at several URLs nor redirected in a loop. The policy
+// takes the place of WithRedirectIndexToDir and
+// WithServeIndexWithoutRedirect, which NewStrict reports when combined
+// with it. Redirects are relative and keep the query.
+func WithCanonicalURLPolicy(policy CanonicalURLPolicy) Option {
+	return func(fs *FileSystemWith404) {
+		fs.canonicalPolicy = policy
+	}
+}
+
+// WithHeadersByContentType sets response headers of served files by their
+// final content type, whether it comes from the extension, the content
+// type resolver, magic detection or sniffing. The keys are media types
+// like "text/html", or patterns like "image/*" and "*/*" that apply when
+// no more specific type sets the header. Headers set by WithHeadersFile or
+// other options are kept, except Vary values which are merged.
+//
+//	WithHeadersByContentType(map[string][]HeaderKV{
+//		"image/*":   {{"Cache-Control", "public, max-age=31536000"}},
+//		"text/html": {{"Cache-Control", "no-cache"}},
+//	})
+func WithHeadersByContentType(headers map[string][]HeaderKV) Option {
+	return func(fs *FileSystemWith404) {
+		fs.typeHeaders = newTypeHeaders(headers)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// interimWriters are the ResponseWriter types of net/http known to send
+// 1xx responses ahead of the final one. Other writers, like
+// httptest.ResponseRecorder or middleware, may take the 103 for the final
+// status.
+var interimWriters = map[string]bool{
+	"*http.response":            true,
+	"*http.http2responseWriter": true,
+}
+
+// supportsInterim reports if the 1xx responses written to w reach the
+// client as interim responses. Wrapping writers, like the header hooks of
+// the handler or middleware, are looked through using their Unwrap method
+// as http.ResponseController does, as they pass interim responses on.
+func supportsInterim(w http.ResponseWriter) bool {
+	for {
+		if interimWriters[fmt.Sprintf("%T", w)] {
+			return true
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = u.Unwrap()
+	}
+}
+
+// addPreloadLinks adds the Link headers configured for the file to the
+// response. With early hints they are sent ahead in a 103 Early Hints
+// response, so clients start fetching them while the file is read.
+// HTTP/1.0 clients do not understand interim responses and only get the
+// headers on the final response, as do ResponseWriters not known to send
+// interim responses and not unwrapping to one.
+func (fs *FileSystemWith404) addPreloadLinks(w http.ResponseWriter, r *http.Request, name string) {
+	links := fs.preloadLinks(name)
+	if len(links) == 0 {
+		return
+	}
+	h := w.Header()
+	for _, link := range links {
+		h.Add("Link", link)
+	}
+	if fs.earlyHints && r.ProtoAtLeast(1, 1) && supportsInterim(w) {
+		w.WriteHeader(http.StatusEarlyHints)
+	}
+}
+
+// informational reports if the status is an interim response, which is
+// followed by the final response
+func informational(code int) bool {
+	return code >= 100 && code < 200 && code != http.StatusSwitchingProtocols
+}
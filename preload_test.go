@@ -0,0 +1,96 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"testing"
+)
+
+func preloadHandler() *FileSystemWith404 {
+	root := MapFS(map[string]string{"index.html": "page", "app.css": "css"})
+	links := func(name string) []string {
+		if name == "/index.html" {
+			return []string{"</app.css>; rel=preload; as=style"}
+		}
+		return nil
+	}
+	return New(root, testNotFound, WithPreloadLinks(links), WithEarlyHints(true), WithServerTiming(true))
+}
+
+func TestEarlyHintsRecorder(t *testing.T) {
+	w := serve(preloadHandler(), "GET", "/")
+	expect(t, w, 200, "page")
+	if got := w.Header().Get("Link"); got != "</app.css>; rel=preload; as=style" {
+		t.Errorf("Link = %q", got)
+	}
+}
+
+// unwrapWriter is middleware wrapping the ResponseWriter with an Unwrap
+// method, opaqueWriter is middleware without
+type unwrapWriter struct{ http.ResponseWriter }
+type opaqueWriter struct{ http.ResponseWriter }
+
+func (w unwrapWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func TestEarlyHintsServer(t *testing.T) {
+	fs := preloadHandler()
+	for _, tc := range []struct {
+		name  string
+		wrap  func(w http.ResponseWriter) http.ResponseWriter
+		hints int
+	}{
+		{"direct", func(w http.ResponseWriter) http.ResponseWriter { return w }, 1},
+		{"unwrap", func(w http.ResponseWriter) http.ResponseWriter { return unwrapWriter{unwrapWriter{w}} }, 1},
+		{"gzip", func(w http.ResponseWriter) http.ResponseWriter { return &gzipWriter{ResponseWriter: w} }, 1},
+		{"opaque", func(w http.ResponseWriter) http.ResponseWriter { return opaqueWriter{w} }, 0},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w = tc.wrap(w)
+			fs.ServeHTTP(w, r)
+			if gw, ok := w.(*gzipWriter); ok {
+				gw.Close()
+			}
+		}))
+
+		var hints []textproto.MIMEHeader
+		trace := &httptrace.ClientTrace{
+			Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+				if code == http.StatusEarlyHints {
+					hints = append(hints, header)
+				}
+				return nil
+			},
+		}
+		req, _ := http.NewRequest("GET", srv.URL+"/", nil)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		srv.Close()
+
+		if tc.name == "gzip" {
+			// Interim responses leave the body to be compressed
+			body = []byte(gunzip(t, bytes.NewReader(body)))
+		}
+		if resp.StatusCode != 200 || string(body) != "page" {
+			t.Errorf("%s: final response = %d %q", tc.name, resp.StatusCode, body)
+		}
+		if len(hints) != tc.hints || (tc.hints > 0 && hints[0].Get("Link") == "") {
+			t.Errorf("%s: early hints = %v, want %d with the Link", tc.name, hints, tc.hints)
+		}
+		if resp.Header.Get("Link") == "" {
+			t.Errorf("%s: final response has no Link", tc.name)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// redirectRule redirects or rewrites requests for matching paths
+type redirectRule struct {
+	pattern *regexp.Regexp
+	names   []string
+	to      string
+	status  int
+	force   bool
+}
+
+// parseRedirectsFile parses a Netlify compatible "_redirects" file. Each
+// line holds a rule "from to [status][!]", the status defaulting to 301.
+// Lines starting with '#' are comments.
+func parseRedirectsFile(r io.Reader) (interface{}, error) {
+	var rules []*redirectRule
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: invalid rule %q", n, sc.Text())
+		}
+
+		rule := &redirectRule{to: fields[1], status: http.StatusMovedPermanently}
+		if len(fields) == 3 {
+			status := fields[2]
+			if strings.HasSuffix(status, "!") {
+				rule.force = true
+				status = strings.TrimSuffix(status, "!")
+			}
+			code, err := strconv.Atoi(status)
+			if err != nil || !supportedRedirect(code) {
+				return nil, fmt.Errorf("line %d: unsupported status %q", n, fields[2])
+			}
+			rule.status = code
+		}
+
+		re, err := splatPattern(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		rule.pattern = re
+		for _, seg := range strings.Split(fields[0], "/") {
+			switch {
+			case strings.HasPrefix(seg, ":") && len(seg) > 1:
+				rule.names = append(rule.names, seg[1:])
+			case strings.Contains(seg, "*"):
+				for i := strings.Count(seg, "*"); i > 0; i-- {
+					rule.names = append(rule.names, "splat")
+				}
+			}
+		}
+		rules = append(rules, rule)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// supportedRedirect reports if the status can be used by a redirect rule
+func supportedRedirect(code int) bool {
+	switch code {
+	case http.StatusOK, http.StatusMovedPermanently, http.StatusFound,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// target returns the destination of the rule for the matched path. The
+// values substituted into a local target can not make it leave the site,
+// so a splat "\evil.com" in "/:splat" gives "/evil.com" and not the
+// "/\evil.com" browsers take for another host.
+func (rule *redirectRule) target(match []string) string {
+	to := rule.to
+	for i, name := range rule.names {
+		to = strings.Replace(to, ":"+name, match[i+1], 1)
+	}
+	if to != rule.to && !absoluteURL(rule.to) && absoluteURL(to) {
+		to = "/" + strings.TrimLeft(to, "/\\ \t\r\n")
+	}
+	return to
+}
+
+// absoluteURL reports if browsers follow the location to another site.
+// They ignore tabs and newlines and treat backslashes like slashes.
+func absoluteURL(location string) bool {
+	location = strings.NewReplacer("\t", "", "\r", "", "\n", "").Replace(location)
+	location = strings.TrimLeft(location, " ")
+	if strings.HasPrefix(location, "//") || strings.HasPrefix(location, "/\\") || strings.HasPrefix(location, "\\") {
+		return true
+	}
+	// A scheme is a letter followed by letters, digits, '+', '-' or '.'
+	i := strings.IndexByte(location, ':')
+	if i < 1 {
+		return false
+	}
+	for j, c := range location[:i] {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case j > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// matchRedirect finds the first redirect rule matching the cleaned path
+// and returns its target and status. Like Netlify, rules are shadowed by
+// files existing at the requested path unless forced using '!'.
+func (fs *FileSystemWith404) matchRedirect(ctx context.Context, urlPath string, rules []*redirectRule, st *serverTiming) (to string, status int, ok bool) {
+	shadowed, checked := false, false
+	for _, rule := range rules {
+		match := rule.pattern.FindStringSubmatch(urlPath)
+		if match == nil {
+			continue
+		}
+		if !rule.force {
+			if !checked {
+				shadowed, checked = fs.exists(ctx, urlPath, st), true
+			}
+			if shadowed {
+				return "", 0, false
+			}
+		}
+		return rule.target(match), rule.status, true
+	}
+	return "", 0, false
+}
+
+// exists reports if the path resolves to a file or a directory index
+func (fs *FileSystemWith404) exists(ctx context.Context, upath string, st *serverTiming) bool {
+	f, d, err := fs.open(ctx, upath, st)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	if !d.IsDir() {
+		return true
+	}
+	_, f, _, err = fs.openIndex(ctx, upath, st)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testRedirectsFile = `# from          to                status
+/old/page.html  /new/page.html
+/news/*         /blog/:splat      302
+/user/:id       /profile.html     200
+/forced.html    /new/page.html    301!
+/exists.html    /new/page.html
+/*              /index.html       200
+`
+
+func TestParseRedirectsFile(t *testing.T) {
+	v, err := parseRedirectsFile(strings.NewReader(testRedirectsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := v.([]*redirectRule)
+	if len(rules) != 6 {
+		t.Fatalf("%d rules, want 6", len(rules))
+	}
+	if rules[0].status != http.StatusMovedPermanently || !rules[3].force {
+		t.Errorf("status %d force %v", rules[0].status, rules[3].force)
+	}
+	for _, bad := range []string{"/a\n", "/a /b 404\n", "/a /b 301 extra\n", "/a /b x!\n"} {
+		if _, err := parseRedirectsFile(strings.NewReader(bad)); err == nil {
+			t.Errorf("parsing %q succeeded", bad)
+		}
+	}
+}
+
+func TestRedirectsFile(t *testing.T) {
+	fs := New(MapFS(map[string]string{
+		"_redirects":    testRedirectsFile,
+		"index.html":    "spa",
+		"profile.html":  "profile",
+		"new/page.html": "new",
+		"exists.html":   "exists",
+		"forced.html":   "forced",
+		"app.js":        "js",
+	}), testNotFound, WithRedirectsFile("/_redirects"))
+
+	for _, c := range []struct {
+		target, location string
+		code             int
+	}{
+		{"/old/page.html", "/new/page.html", http.StatusMovedPermanently},
+		{"/news/2021/launch", "/blog/2021/launch", http.StatusFound},
+		{"/news/a?ref=x", "/blog/a?ref=x", http.StatusFound},
+		{"/forced.html", "/new/page.html", http.StatusMovedPermanently},
+	} {
+		w := serve(fs, http.MethodGet, c.target)
+		expect(t, w, c.code, "")
+		if got := w.Header().Get("Location"); got != c.location {
+			t.Errorf("%s: Location = %q, want %q", c.target, got, c.location)
+		}
+	}
+
+	// Rewrites, existing files shadowing the rules
+	expect(t, serve(fs, http.MethodGet, "/user/42"), http.StatusOK, "profile")
+	expect(t, serve(fs, http.MethodGet, "/exists.html"), http.StatusOK, "exists")
+	expect(t, serve(fs, http.MethodGet, "/app.js"), http.StatusOK, "js")
+	expect(t, serve(fs, http.MethodGet, "/dashboard/settings"), http.StatusOK, "spa")
+	expect(t, serve(fs, http.MethodGet, "/_redirects"), http.StatusNotFound, notFoundBody)
+}
+
+func TestRedirectsStayLocal(t *testing.T) {
+	fs := New(MapFS(map[string]string{
+		"_redirects": "/blog/* /:splat 301\n/go/:to :to 302\n/ext/* https://example.org/:splat 301\n",
+	}), testNotFound, WithRedirectsFile("/_redirects"))
+
+	for target, location := range map[string]string{
+		"/blog//evil.com/x":      "/evil.com/x",
+		"/blog/%5Cevil.com":      "/evil.com",
+		"/blog/%2F%5Cevil.com":   "/evil.com",
+		"/blog/%09/evil.com":     "/evil.com",
+		"/go/http:evil.com":      "/http:evil.com",
+		"/go/javascript:alert()": "/javascript:alert()",
+		"/blog/a/b":              "/a/b",
+		"/ext/a":                 "https://example.org/a",
+	} {
+		w := serve(fs, http.MethodGet, target)
+		if got := w.Header().Get("Location"); got != location {
+			t.Errorf("%s: Location = %q, want %q", target, got, location)
+		}
+	}
+}
+
+func TestRedirectsRewriteExcluded(t *testing.T) {
+	fs := New(MapFS(map[string]string{
+		"_redirects": "/js /app.js 200\n/map /app.js.map 200\n/env /.env 200\n/nul /nul.txt 200\n/h /x/../_headers 200\n",
+		"_headers":   "/*\n  X-A: b\n",
+		"app.js":     "js",
+		"app.js.map": "map",
+		".env":       "secret",
+		"nul.txt":    "reserved",
+	}), testNotFound, WithRedirectsFile("/_redirects"), WithHeadersFile("/_headers"),
+		WithDenyGlobs("/*.map"), WithRejectReservedNames(true))
+
+	expect(t, serve(fs, http.MethodGet, "/js"), http.StatusOK, "js")
+	for _, target := range []string{"/map", "/env", "/nul", "/h"} {
+		expect(t, serve(fs, http.MethodGet, target), http.StatusNotFound, notFoundBody)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"strings"
+)
+
+// reservedNames are the device names of Windows, reserved with any
+// extension and in any case.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+	"CONIN$": true, "CONOUT$": true,
+}
+
+// reservedChars are not allowed in Windows file names
+const reservedChars = `<>:"\|?*`
+
+// reservedSegment reports if the path segment is a name that does not
+// refer to the same file on every operating system. These are Windows
+// device names, names ending in a dot or space, which Windows strips, and
+// names with characters Windows does not allow.
+func reservedSegment(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return true
+	}
+	for _, c := range name {
+		if c < 0x20 || strings.ContainsRune(reservedChars, c) {
+			return true
+		}
+	}
+
+	base := name
+	if i := strings.IndexByte(base, '.'); i >= 0 {
+		base = base[:i]
+	}
+	return reservedNames[strings.ToUpper(strings.TrimRight(base, " "))]
+}
+
+// reservedPath reports if any segment of the path is reserved
+func reservedPath(upath string) bool {
+	for _, p := range strings.Split(upath, "/") {
+		if reservedSegment(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestReservedSegment(t *testing.T) {
+	for name, want := range map[string]bool{
+		"":            false,
+		".":           false,
+		"..":          false,
+		"index.html":  false,
+		"console.log": false,
+		"com10":       false,
+		"CON":         true,
+		"con":         true,
+		"Con.txt":     true,
+		"nul.tar.gz":  true,
+		"COM1":        true,
+		"lpt9.doc":    true,
+		"AUX .txt":    true,
+		"CONIN$":      true,
+		"page.":       true,
+		"page ":       true,
+		"a<b":         true,
+		"a>b":         true,
+		"a:b":         true,
+		`a"b`:         true,
+		`a\b`:         true,
+		"a|b":         true,
+		"a?b":         true,
+		"a*b":         true,
+		"a\x01b":      true,
+	} {
+		if got := reservedSegment(name); got != want {
+			t.Errorf("reservedSegment(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if !reservedPath("/docs/CON/a.txt") || reservedPath("/docs/a.txt") {
+		t.Errorf("reservedPath does not check every segment")
+	}
+}
+
+func TestRejectReservedNames(t *testing.T) {
+	files := map[string]string{"page.html": "page", "nul.txt": "nul", "docs/a.txt": "a"}
+	fs := New(MapFS(files), testNotFound, WithRejectReservedNames(true))
+	for _, target := range []string{"/page.html.", "/page.html%20", "/nul.txt", "/NUL.txt", "/docs./a.txt", "/a%3Ab"} {
+		expect(t, serve(fs, http.MethodGet, target), http.StatusNotFound, notFoundBody)
+	}
+	expect(t, serve(fs, http.MethodGet, "/page.html"), http.StatusOK, "page")
+	expect(t, serve(fs, http.MethodGet, "/docs/a.txt"), http.StatusOK, "a")
+
+	fs = New(MapFS(files), testNotFound)
+	expect(t, serve(fs, http.MethodGet, "/nul.txt"), http.StatusOK, "nul")
+}
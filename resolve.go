@@ -0,0 +1,534 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// robotsPath is the path crawlers read their rules from
+const robotsPath = "/robots.txt"
+
+// resolutionKind tells how a request is to be answered
+type resolutionKind int
+
+const (
+	// resolveNotFound answers using the notFound handler
+	resolveNotFound resolutionKind = iota
+	// resolveHidden answers a request blocked by the hidden file filter
+	resolveHidden
+	// resolveFile serves the resolved file
+	resolveFile
+	// resolveFallback serves the SPA fallback page
+	resolveFallback
+	// resolveRedirect redirects to the location
+	resolveRedirect
+	// resolveError answers with the error status
+	resolveError
+	// resolveListing lists the opened directory
+	resolveListing
+	// resolveRobots serves the robots.txt fallback content
+	resolveRobots
+	// resolveRoot hands the root without an index to the root handler
+	resolveRoot
+	// resolveManifest serves the generated asset manifest
+	resolveManifest
+)
+
+// resolution describes the decision taken for a request, without
+// anything having been written to the response yet.
+type resolution struct {
+	kind resolutionKind
+
+	// name, file and info describe the file to serve. The file is
+	// owned by the resolution and released using close.
+	name string
+	file http.File
+	info os.FileInfo
+	// language is the Content-Language of a negotiated variant
+	language string
+	// immutable files are served to be cached forever
+	immutable bool
+	// baseHref is injected into index pages served without redirect
+	baseHref string
+
+	// location and status describe redirects and errors. Local
+	// redirects are relative to the request path and have no body.
+	location string
+	status   int
+	local    bool
+
+	// path is the request path after the rewrite rules
+	path string
+}
+
+// close releases the file of the resolution
+func (res *resolution) close() {
+	if res.file != nil {
+		res.file.Close()
+		res.file = nil
+	}
+}
+
+// resolve decides how the request is answered. The error is only set
+// when the request could not be resolved at all, like when the client
+// went away.
+func (fs *FileSystemWith404) resolve(r *http.Request, st *serverTiming) (resolution, error) {
+	res, err := fs.resolvePath(r, st)
+	if res.path == "" {
+		res.path = r.URL.Path
+		if !strings.HasPrefix(res.path, "/") {
+			res.path = "/" + res.path
+		}
+	}
+	return res, err
+}
+
+// resolvePath resolves the request path, reporting the rewritten path in
+// the resolution when a rewrite rule applied.
+func (fs *FileSystemWith404) resolvePath(r *http.Request, st *serverTiming) (resolution, error) {
+	ctx := r.Context()
+
+	// Find out the Path
+	urlPath := r.URL.Path
+	if !strings.HasPrefix(urlPath, "/") {
+		urlPath = "/" + urlPath
+	}
+	upath := path.Clean(urlPath)
+	if res, ok := fs.excluded(ctx, urlPath, upath); ok {
+		return res, nil
+	}
+
+	// The aliases and the redirect rules match the cleaned path keeping
+	// its trailing slash
+	mpath := upath
+	if strings.HasSuffix(urlPath, "/") && upath != "/" {
+		mpath += "/"
+	}
+
+	// Moved paths from the alias map
+	if fs.aliases != nil {
+		if to, ok := fs.aliases.target(mpath); ok {
+			return redirectTo(r, to, http.StatusMovedPermanently), nil
+		}
+	}
+
+	// Apply the redirect and rewrite rules
+	if fs.redirectsFile != nil {
+		if rules, ok := fs.redirectsFile.get(ctx, fs).([]*redirectRule); ok {
+			if to, status, ok := fs.matchRedirect(ctx, mpath, rules, st); ok {
+				if status != http.StatusOK {
+					return redirectTo(r, to, status), nil
+				}
+				if !strings.HasPrefix(to, "/") {
+					to = "/" + to
+				}
+				if res, ok := fs.excluded(ctx, to, path.Clean(to)); ok {
+					return res, nil
+				}
+				res, err := fs.resolveTarget(r, to, st)
+				res.path = to
+				return res, err
+			}
+		}
+	}
+
+	if fs.canonicalPolicy != PolicyNone {
+		if res, ok := fs.canonicalRedirect(r, urlPath, st); ok {
+			return res, nil
+		}
+	}
+
+	// Index pages are only linked by their directory
+	if fs.redirectIndex && fs.isIndexPage(ctx, urlPath, st) {
+		res := redirectTo(r, "./", http.StatusMovedPermanently)
+		res.local = true
+		return res, nil
+	}
+
+	if fs.immutablePrefix != "" && strings.HasPrefix(urlPath, fs.immutablePrefix) {
+		return fs.resolveImmutable(r, urlPath, st)
+	}
+	return fs.resolveTarget(r, urlPath, st)
+}
+
+// excluded returns the resolution of a path which is never served, like
+// hidden and denied ones. Both the path as requested and as cleaned are
+// checked, so dot segments can not hide a name.
+func (fs *FileSystemWith404) excluded(ctx context.Context, urlPath, upath string) (resolution, bool) {
+	// Filter out .files or hidden dot files
+	if fs.hidden(ctx, urlPath) || (upath != urlPath && fs.hidden(ctx, upath)) {
+		return resolution{kind: resolveHidden}, true
+	}
+
+	// Names meaning other files depending on the operating system
+	if fs.rejectReserved && (reservedPath(urlPath) || (upath != urlPath && reservedPath(upath))) {
+		return resolution{kind: resolveNotFound}, true
+	}
+
+	// Paths excluded using the glob lists
+	if fs.globDenied(upath) {
+		return resolution{kind: resolveNotFound}, true
+	}
+
+	// The configuration files are never served
+	if fs.isConfigFile(upath) {
+		return resolution{kind: resolveNotFound}, true
+	}
+	return resolution{}, false
+}
+
+// isIndexPage reports if the path names an index page of its directory
+func (fs *FileSystemWith404) isIndexPage(ctx context.Context, urlPath string, st *serverTiming) bool {
+	if strings.HasSuffix(urlPath, "/") {
+		return false
+	}
+	dir, name := path.Split(path.Clean(urlPath))
+	for _, index := range fs.indexCandidates(ctx, dir, st) {
+		if index == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveTarget resolves the path after the rewrite rules to a file
+func (fs *FileSystemWith404) resolveTarget(r *http.Request, urlPath string, st *serverTiming) (resolution, error) {
+	ctx := r.Context()
+	upath := path.Clean(urlPath)
+
+	if fs.manifest != nil && upath == fs.manifest.name {
+		return resolution{kind: resolveManifest, name: upath}, nil
+	}
+
+	if fs.robotsFallback != "" && upath == robotsPath {
+		f, d, err := fs.open(ctx, upath, st)
+		if err != nil {
+			return resolution{kind: resolveRobots}, nil
+		}
+		if d.IsDir() {
+			f.Close()
+			return resolution{kind: resolveRobots}, nil
+		}
+		return fs.resolveFile(r, upath, f, d, st)
+	}
+
+	if len(fs.tryFiles) > 0 {
+		return fs.resolveTryFiles(r, urlPath, st)
+	}
+
+	// Replace or Dir Lising to Index Pages
+	if strings.HasSuffix(urlPath, "/") {
+		name, f, d, err := fs.openIndex(ctx, upath, st)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return resolution{}, err
+			}
+			if upath == "/" && fs.rootHandler != nil {
+				return resolution{kind: resolveRoot}, nil
+			}
+			if fs.listable(upath) {
+				return fs.resolveListing(ctx, upath, st)
+			}
+			return fs.resolveMissing(ctx, st)
+		}
+		return fs.resolveFile(r, name, f, d, st)
+	}
+
+	// Try to Open the File
+	f, d, err := fs.open(ctx, upath, st)
+	if err != nil && fs.canonicalPolicy == PolicyExtensionless && upath != "/" {
+		// Pages are served without their extension
+		f, d, err = fs.open(ctx, upath+htmlExt, st)
+		if err == nil && !d.IsDir() {
+			return fs.resolveFile(r, upath+htmlExt, f, d, st)
+		}
+		if err == nil {
+			f.Close()
+			err = os.ErrNotExist
+		}
+	}
+	if err != nil {
+		// Else its actually an Invalid file
+		return fs.resolveMissing(ctx, st)
+	}
+
+	if d.IsDir() {
+		f.Close() // Force Close the Directory
+
+		// Its just a Dir name that might contain an Index file
+		if fs.indexWithoutRedirect || fs.canonicalPolicy == PolicyNoTrailingSlash {
+			if name, f, d, err := fs.openIndex(ctx, upath, st); err == nil {
+				res, err := fs.resolveFile(r, name, f, d, st)
+				// Relative links of the page resolve against the directory
+				res.baseHref = path.Base(urlPath) + "/"
+				return res, err
+			}
+		}
+		res := redirectTo(r, path.Base(urlPath)+"/", http.StatusMovedPermanently)
+		res.local = true
+		return res, nil
+	}
+
+	// Serve the file since we know it actually exists
+	return fs.resolveFile(r, upath, f, d, st)
+}
+
+// resolveFile decides on serving the opened file, or the language variant
+// of it negotiated for the request.
+func (fs *FileSystemWith404) resolveFile(r *http.Request, name string, f http.File, d os.FileInfo, st *serverTiming) (resolution, error) {
+	res := resolution{kind: resolveFile, name: name, file: f, info: d}
+
+	if fs.languageNegotiation {
+		if vname, vf, vd, lang, ok := fs.openLanguageVariant(r, name, st); ok {
+			f.Close()
+			res.name, res.file, res.info, res.language = vname, vf, vd, lang
+		}
+	}
+
+	if !fs.servable(res.name, res.info) {
+		res.close()
+		return resolution{kind: resolveNotFound}, nil
+	}
+	return res, nil
+}
+
+// resolveListing decides on listing the directory without an index
+func (fs *FileSystemWith404) resolveListing(ctx context.Context, dir string, st *serverTiming) (resolution, error) {
+	f, d, err := fs.open(ctx, dir, st)
+	if err != nil {
+		return fs.resolveMissing(ctx, st)
+	}
+	if !d.IsDir() {
+		f.Close()
+		return fs.resolveMissing(ctx, st)
+	}
+	return resolution{kind: resolveListing, name: dir, file: f, info: d}, nil
+}
+
+// resolveMissing decides on requests that do not resolve to a servable
+// file, using the SPA fallback page when configured.
+func (fs *FileSystemWith404) resolveMissing(ctx context.Context, st *serverTiming) (resolution, error) {
+	if err := ctx.Err(); err != nil {
+		return resolution{}, err
+	}
+	if fs.spaFallback == "" {
+		return resolution{kind: resolveNotFound}, nil
+	}
+
+	f, d, err := fs.open(ctx, fs.spaFallback, st)
+	if err != nil {
+		return resolution{kind: resolveNotFound}, nil
+	}
+	if d.IsDir() {
+		f.Close()
+		return resolution{kind: resolveNotFound}, nil
+	}
+	return resolution{kind: resolveFallback, name: fs.spaFallback, file: f, info: d}, nil
+}
+
+// redirectTo describes a redirect keeping the query of the request
+// unless the location has its own.
+func redirectTo(r *http.Request, location string, status int) resolution {
+	if q := r.URL.RawQuery; q != "" && !strings.Contains(location, "?") {
+		location += "?" + q
+	}
+	return resolution{kind: resolveRedirect, location: location, status: status}
+}
+
+// hidden reports if the path contains hidden dot file or directory names.
+// With hidden directories only, a hidden last segment is only reported
+// when it names a directory, which costs a Stat of it.
+func (fs *FileSystemWith404) hidden(ctx context.Context, upath string) bool {
+	// The segments after the first '/' are scanned without splitting
+	i := strings.IndexByte(upath, '/')
+	if i < 0 {
+		return false
+	}
+	for rest := upath[i+1:]; ; {
+		p, last := rest, true
+		if j := strings.IndexByte(rest, '/'); j >= 0 {
+			p, rest, last = rest[:j], rest[j+1:], false
+		}
+		if strings.HasPrefix(p, ".") {
+			if !fs.hiddenDirsOnly || p == "." || p == ".." || !last {
+				return true
+			}
+
+			// A hidden leaf is allowed unless it is a directory
+			f, d, err := fs.open(ctx, path.Clean(upath), nil)
+			if err != nil {
+				return false
+			}
+			f.Close()
+			return d.IsDir()
+		}
+		if last {
+			return false
+		}
+	}
+}
+
+// servable reports if the resolved file may be served
+func (fs *FileSystemWith404) servable(name string, d os.FileInfo) bool {
+	// Empty files are treated as broken deployments
+	if fs.rejectEmpty && d.Size() == 0 {
+		return false
+	}
+	if fs.maxFileSize > 0 && d.Size() > fs.maxFileSize {
+		return false
+	}
+	// Pipes, sockets and devices would block or leak on reading
+	if fs.rejectSpecial && !d.Mode().IsRegular() {
+		return false
+	}
+	return !fs.isConfigFile(name)
+}
+
+// entryInfo returns the info of the directory entry read by Readdir,
+// following a symbolic link like opening the entry does. It reports false
+// for broken links and links to files that are not served.
+func (fs *FileSystemWith404) entryInfo(ctx context.Context, upath string, d os.FileInfo) (os.FileInfo, bool) {
+	if d.Mode()&os.ModeSymlink == 0 {
+		return d, true
+	}
+	f, info, err := fs.open(ctx, upath, nil)
+	if err != nil {
+		return nil, false
+	}
+	f.Close()
+	return info, true
+}
+
+// revisited reports if the directory is one of its ancestors, reached
+// again through a symbolic link
+func revisited(ancestors []os.FileInfo, d os.FileInfo) bool {
+	for _, a := range ancestors {
+		if os.SameFile(a, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// isConfigFile reports if the path is one of the configuration files
+func (fs *FileSystemWith404) isConfigFile(upath string) bool {
+	return (fs.headersFile != nil && upath == fs.headersFile.name) ||
+		(fs.redirectsFile != nil && upath == fs.redirectsFile.name) ||
+		(fs.directoryDefaultFile != "" && path.Base(upath) == fs.directoryDefaultFile)
+}
+
+// indexCandidates returns the ordered index file names for the directory.
+// A default document named for the directory takes precedence over the
+// index pages.
+func (fs *FileSystemWith404) indexCandidates(ctx context.Context, dir string, st *serverTiming) []string {
+	if fs.directoryDefault != nil {
+		if name := fs.directoryDefault(dir); name != "" {
+			return []string{name}
+		}
+	}
+	if fs.directoryDefaultFile != "" {
+		if name := fs.readDirectoryDefault(ctx, dir, st); name != "" {
+			return []string{name}
+		}
+	}
+	if fs.indexResolver != nil {
+		return fs.indexResolver(dir)
+	}
+	return fs.indexPages
+}
+
+// readDirectoryDefault returns the document named by the convention file
+// of the directory, the first non-empty line of it.
+func (fs *FileSystemWith404) readDirectoryDefault(ctx context.Context, dir string, st *serverTiming) string {
+	f, d, err := fs.open(ctx, path.Join(dir, fs.directoryDefaultFile), st)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	if d.IsDir() {
+		return ""
+	}
+
+	b, err := io.ReadAll(io.LimitReader(f, 1024))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// openIndex opens the first index candidate of the directory that
+// exists, is no directory itself and is servable. Candidates must name
+// files directly within the directory that pass the hidden file filter.
+// Candidates failing to open are skipped, a BackendError like from a
+// failing Fetcher stops the lookup and is returned. Without any candidate
+// to serve os.ErrNotExist is returned.
+func (fs *FileSystemWith404) openIndex(ctx context.Context, dir string, st *serverTiming) (string, http.File, os.FileInfo, error) {
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+	for _, index := range fs.indexCandidates(ctx, dir, st) {
+		name := path.Join(dir, index)
+		if path.Dir(name) != path.Clean(dir) || fs.hidden(ctx, name) {
+			continue
+		}
+		f, d, err := fs.open(ctx, name, st)
+		if err != nil {
+			if isBackendError(err) {
+				return "", nil, nil, err
+			}
+			continue
+		}
+		if d.IsDir() || !fs.servable(name, d) {
+			f.Close()
+			continue
+		}
+		return name, f, d, nil
+	}
+	return "", nil, nil, os.ErrNotExist
+}
+
+// open opens the named file from the root and returns its file info
+func (fs *FileSystemWith404) open(ctx context.Context, name string, st *serverTiming) (http.File, os.FileInfo, error) {
+	start := st.start()
+	root := fs.Root()
+	if dir, ok := root.(http.Dir); ok && fs.rejectSpecial {
+		// The file info comes with opening, without another Stat
+		f, d, err := openRegular(dir, name)
+		st.measure("open", start)
+		return f, d, err
+	}
+	var f http.File
+	var err error
+	if co, ok := root.(contextOpener); ok {
+		f, err = co.openContext(ctx, name)
+	} else {
+		f, err = root.Open(name)
+	}
+	st.measure("open", start)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start = st.start()
+	d, err := f.Stat()
+	st.measure("stat", start)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, d, nil
+}
@@ -0,0 +1,332 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// errorFetcher serves the files of the MapFS, failing with the error for
+// the names in errs
+type errorFetcher struct {
+	files Fetcher
+	errs  map[string]error
+}
+
+func (f errorFetcher) Fetch(ctx context.Context, name string) (io.ReadSeeker, os.FileInfo, error) {
+	if err, ok := f.errs[name]; ok {
+		return nil, nil, err
+	}
+	return f.files.Fetch(ctx, name)
+}
+
+func TestIndexCandidates(t *testing.T) {
+	files := map[string]string{
+		"docs/index.html/a.txt": "directory",
+		"docs/index.txt":        "final",
+	}
+	fetcher := errorFetcher{
+		files: FileSystemFetcher(MapFS(files)),
+		errs:  map[string]error{"/docs/index.htm": os.ErrPermission},
+	}
+	fs := NewFetcher(fetcher, testNotFound, WithIndexPages("missing.html", "index.html", "index.htm", "index.txt"))
+	expect(t, serve(fs, http.MethodGet, "/docs/"), http.StatusOK, "final")
+}
+
+func TestIndexBackendError(t *testing.T) {
+	fetcher := errorFetcher{
+		files: FileSystemFetcher(MapFS(map[string]string{"index.txt": "final"})),
+		errs:  map[string]error{"/index.html": errors.New("storage unreachable")},
+	}
+	fs := NewFetcher(fetcher, testNotFound, WithIndexPages("index.html", "index.txt"))
+	w := serve(fs, http.MethodGet, "/")
+	expect(t, w, http.StatusInternalServerError, "")
+	if strings.Contains(w.Body.String(), notFoundBody) {
+		t.Errorf("backend failure answered with the notFound handler")
+	}
+}
+
+func TestInvalidPaths(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "index.html"), []byte("root"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	long := "/" + strings.Repeat("a", 300) + "/"
+	for name, fsys := range map[string]http.FileSystem{
+		"Dir":   http.Dir(root),
+		"MapFS": MapFS(map[string]string{"index.html": "root"}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			fs := New(fsys, testNotFound)
+			for _, target := range []string{long, "/x%00y/", "/index.html/"} {
+				expect(t, serve(fs, http.MethodGet, target), http.StatusNotFound, notFoundBody)
+			}
+			expect(t, serve(fs, http.MethodGet, "/"), http.StatusOK, "root")
+		})
+	}
+}
+
+func TestIndexResolver(t *testing.T) {
+	files := map[string]string{
+		"index.html":         "root",
+		"docs/README.md":     "docs readme",
+		"docs/index.html":    "docs index",
+		"blog/home.htm":      "blog home",
+		"blog/index.html":    "blog index",
+		"blog/2021/post.htm": "post",
+		"blog/2021/home.htm": "2021 home",
+	}
+	fs := New(MapFS(files), testNotFound, WithIndexPages("home.htm", "index.html"), WithIndexResolver(func(dir string) []string {
+		switch {
+		case dir == "/docs/":
+			return []string{"README.md", "index.html"}
+		case strings.HasPrefix(dir, "/blog/"):
+			return nil
+		}
+		return []string{"index.html"}
+	}))
+	expect(t, serve(fs, http.MethodGet, "/"), http.StatusOK, "root")
+	expect(t, serve(fs, http.MethodGet, "/docs/"), http.StatusOK, "docs readme")
+	expect(t, serve(fs, http.MethodGet, "/blog/"), http.StatusNotFound, notFoundBody)
+
+	fs = New(MapFS(files), testNotFound, WithIndexPages("home.htm", "index.html"))
+	expect(t, serve(fs, http.MethodGet, "/blog/"), http.StatusOK, "blog home")
+	expect(t, serve(fs, http.MethodGet, "/blog/2021/"), http.StatusOK, "2021 home")
+	expect(t, serve(fs, http.MethodGet, "/docs/"), http.StatusOK, "docs index")
+}
+
+func TestServeIndexWithoutRedirect(t *testing.T) {
+	files := map[string]string{"docs/index.txt": "docs", "empty/a.txt": "a"}
+	for _, enable := range []bool{false, true} {
+		fs := New(MapFS(files), testNotFound, WithIndexPages("index.txt"), WithServeIndexWithoutRedirect(enable))
+		w := serve(fs, http.MethodGet, "/docs")
+		if enable {
+			expect(t, w, http.StatusOK, "docs")
+		} else {
+			expect(t, w, http.StatusMovedPermanently, "")
+			if got := w.Header().Get("Location"); got != "docs/" {
+				t.Errorf("Location = %q, want docs/", got)
+			}
+		}
+		expect(t, serve(fs, http.MethodGet, "/docs/"), http.StatusOK, "docs")
+
+		// Directories without an index are always redirected
+		expect(t, serve(fs, http.MethodGet, "/empty"), http.StatusMovedPermanently, "")
+		expect(t, serve(fs, http.MethodGet, "/empty/"), http.StatusNotFound, notFoundBody)
+	}
+}
+
+func TestDotFileHandler(t *testing.T) {
+	fs := New(MapFS(map[string]string{
+		".env":        "secret",
+		".git/config": "secret",
+		"a.txt":       "a",
+	}), testNotFound, WithDotFileHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "dot file")
+	}))
+	for _, target := range []string{"/.env", "/.git/config", "/.git/", "/.missing", "/a/../.env"} {
+		expect(t, serve(fs, http.MethodGet, target), http.StatusForbidden, "dot file")
+	}
+	expect(t, serve(fs, http.MethodGet, "/a.txt"), http.StatusOK, "a")
+	expect(t, serve(fs, http.MethodGet, "/missing"), http.StatusNotFound, notFoundBody)
+	expect(t, serve(fs, http.MethodGet, "/env"), http.StatusNotFound, notFoundBody)
+}
+
+func TestRejectEmptyFiles(t *testing.T) {
+	files := map[string]string{"empty.js": "", "docs/index.html": "", "a.js": "a"}
+	fs := New(MapFS(files), testNotFound)
+	w := serve(fs, http.MethodGet, "/empty.js")
+	expect(t, w, http.StatusOK, "")
+	if w.Body.Len() != 0 || w.Header().Get("Content-Length") != "0" {
+		t.Errorf("empty file served %q, Content-Length %q", w.Body.String(), w.Header().Get("Content-Length"))
+	}
+	expect(t, serve(fs, http.MethodGet, "/docs/"), http.StatusOK, "")
+
+	fs = New(MapFS(files), testNotFound, WithRejectEmptyFiles(true))
+	expect(t, serve(fs, http.MethodGet, "/empty.js"), http.StatusNotFound, notFoundBody)
+	expect(t, serve(fs, http.MethodGet, "/docs/"), http.StatusNotFound, notFoundBody)
+	expect(t, serve(fs, http.MethodGet, "/a.js"), http.StatusOK, "a")
+}
+
+func TestDirectoryDefault(t *testing.T) {
+	files := map[string]string{
+		"index.html":        "root",
+		"docs/README.html":  "docs readme",
+		"docs/index.html":   "docs index",
+		"blog/latest.html":  "blog latest",
+		"blog/.serveindex":  "\n latest.html \nignored.html\n",
+		"shop/.serveindex":  "missing.html\n",
+		"shop/index.html":   "shop index",
+		"other/index.html":  "other index",
+		"other/.serveindex": "../index.html\n",
+	}
+	fs := New(MapFS(files), testNotFound, WithDirectoryDefault(func(dir string) string {
+		if dir == "/docs/" {
+			return "README.html"
+		}
+		return ""
+	}))
+	expect(t, serve(fs, http.MethodGet, "/docs/"), http.StatusOK, "docs readme")
+	expect(t, serve(fs, http.MethodGet, "/"), http.StatusOK, "root")
+	expect(t, serve(fs, http.MethodGet, "/shop/"), http.StatusOK, "shop index")
+
+	fs = New(MapFS(files), testNotFound, WithDirectoryDefaultFile(".serveindex"), WithHiddenDirsOnly(true))
+	expect(t, serve(fs, http.MethodGet, "/blog/"), http.StatusOK, "blog latest")
+	expect(t, serve(fs, http.MethodGet, "/docs/"), http.StatusOK, "docs index")
+	expect(t, serve(fs, http.MethodGet, "/shop/"), http.StatusNotFound, notFoundBody)
+	expect(t, serve(fs, http.MethodGet, "/other/"), http.StatusNotFound, notFoundBody)
+	expect(t, serve(fs, http.MethodGet, "/blog/.serveindex"), http.StatusNotFound, notFoundBody)
+}
+
+func TestHiddenDirsOnly(t *testing.T) {
+	files := map[string]string{
+		".htaccess":        "leaf",
+		"sub/.nojekyll":    "nested leaf",
+		".git/config":      "secret",
+		".git/HEAD":        "secret",
+		"a/.cache/x.js":    "secret",
+		".well-known/x.js": "secret",
+	}
+	fs := New(MapFS(files), testNotFound, WithHiddenDirsOnly(true))
+	expect(t, serve(fs, http.MethodGet, "/.htaccess"), http.StatusOK, "leaf")
+	expect(t, serve(fs, http.MethodGet, "/sub/.nojekyll"), http.StatusOK, "nested leaf")
+	for _, target := range []string{"/.git/config", "/.git", "/.git/", "/a/.cache/x.js", "/a/.cache", "/.well-known/x.js", "/sub/./.nojekyll/.."} {
+		expect(t, serve(fs, http.MethodGet, target), http.StatusNotFound, notFoundBody)
+	}
+
+	fs = New(MapFS(files), testNotFound)
+	expect(t, serve(fs, http.MethodGet, "/.htaccess"), http.StatusNotFound, notFoundBody)
+}
+
+func TestDotSegments(t *testing.T) {
+	files := MapFS(map[string]string{
+		"index.html":     "root",
+		"foo/index.html": "foo",
+		"foo/.../bar":    "bar",
+		".env":           "secret",
+		"foo/.env":       "secret",
+	})
+	var dotted []string
+	for _, opts := range [][]Option{
+		nil,
+		{WithHiddenDirsOnly(true)},
+		{WithServeIndexWithoutRedirect(true)},
+		{WithDotFileHandler(func(w http.ResponseWriter, r *http.Request) {
+			dotted = append(dotted, r.URL.Path)
+			testNotFound(w, r)
+		})},
+	} {
+		fs := New(files, testNotFound, opts...)
+		for _, target := range []string{
+			"/foo/.",
+			"/foo/..",
+			"/foo/./",
+			"/foo/../",
+			"/foo/.../bar",
+			"/foo/...",
+			"/foo/../.env",
+			"/foo/./.env",
+			"/foo/.env/..",
+		} {
+			expect(t, serve(fs, http.MethodGet, target), http.StatusNotFound, notFoundBody)
+		}
+		expect(t, serve(fs, http.MethodGet, "/foo/"), http.StatusOK, "foo")
+	}
+	// Dot segments are handled as hidden files
+	if len(dotted) != 9 {
+		t.Errorf("dot file handler got %v", dotted)
+	}
+
+	// Paths rewritten into dot segments are hidden too
+	fs := New(files, testNotFound, WithPathRewriter(func(upath string) string {
+		return strings.Replace(upath, "/up/", "/../", 1)
+	}))
+	expect(t, serve(fs, http.MethodGet, "/foo/up/.env"), http.StatusNotFound, notFoundBody)
+	expect(t, serve(fs, http.MethodGet, "/foo/up/index.html"), http.StatusNotFound, notFoundBody)
+}
+
+func TestResolve(t *testing.T) {
+	fs := New(MapFS(map[string]string{
+		"index.html":      "root",
+		"a.txt":           "a",
+		"docs/index.html": "docs",
+		"empty/x.txt":     "x",
+		".env":            "secret",
+		"app/index.html":  "app",
+	}), testNotFound, WithDirectoryListing(true), WithRobotsFallback("User-agent: *\n"), WithSPAFallback("/app/index.html"),
+		WithPathRewriter(func(upath string) string { return strings.TrimPrefix(upath, "/v1") }))
+
+	for _, c := range []struct {
+		target   string
+		kind     resolutionKind
+		name     string
+		location string
+	}{
+		{"/a.txt", resolveFile, "/a.txt", ""},
+		{"/v1/a.txt", resolveFile, "/a.txt", ""},
+		{"/", resolveFile, "/index.html", ""},
+		{"/docs/", resolveFile, "/docs/index.html", ""},
+		{"/docs", resolveRedirect, "", "docs/"},
+		{"/empty/", resolveListing, "/empty", ""},
+		{"/.env", resolveHidden, "", ""},
+		{"/robots.txt", resolveRobots, "", ""},
+		{"/missing", resolveFallback, "/app/index.html", ""},
+	} {
+		r := httptest.NewRequest(http.MethodGet, c.target, nil)
+		if fs.pathRewriter != nil {
+			r = rewritePath(r, fs.pathRewriter(r.URL.Path))
+		}
+		res, err := fs.resolve(r, nil)
+		if err != nil {
+			t.Errorf("%s: %v", c.target, err)
+			continue
+		}
+		if res.kind != c.kind || res.name != c.name || res.location != c.location {
+			t.Errorf("%s: resolved %v %q %q, want %v %q %q", c.target, res.kind, res.name, res.location, c.kind, c.name, c.location)
+		}
+		if (res.file != nil) != (c.kind == resolveFile || c.kind == resolveFallback || c.kind == resolveListing) {
+			t.Errorf("%s: resolution file %v", c.target, res.file)
+		}
+		res.close()
+	}
+
+	// A request resolving to nothing without a fallback
+	fs = New(MapFS(nil), testNotFound)
+	res, err := fs.resolve(httptest.NewRequest(http.MethodGet, "/missing", nil), nil)
+	if err != nil || res.kind != resolveNotFound || res.path != "/missing" {
+		t.Errorf("resolved %v %q %v, want not found", res.kind, res.path, err)
+	}
+}
+
+func TestRobotsFallback(t *testing.T) {
+	rules := "User-agent: *\nDisallow: /private/\n"
+	fs := New(MapFS(map[string]string{"index.html": "home"}), testNotFound, WithRobotsFallback(rules))
+	w := serve(fs, http.MethodGet, "/robots.txt")
+	expect(t, w, http.StatusOK, rules)
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	w = serve(fs, http.MethodHead, "/robots.txt")
+	expect(t, w, http.StatusOK, "")
+	if w.Body.Len() != 0 {
+		t.Errorf("HEAD has a body")
+	}
+	expect(t, serve(fs, http.MethodGet, "/sub/robots.txt"), http.StatusNotFound, notFoundBody)
+
+	fs = New(MapFS(map[string]string{"robots.txt": "real"}), testNotFound, WithRobotsFallback(rules))
+	expect(t, serve(fs, http.MethodGet, "/robots.txt"), http.StatusOK, "real")
+
+	fs = New(MapFS(nil), testNotFound)
+	expect(t, serve(fs, http.MethodGet, "/robots.txt"), http.StatusNotFound, notFoundBody)
+}
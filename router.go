@@ -0,0 +1,122 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Router serves several file systems, each mounted at a URL path prefix
+type Router struct {
+	// mounts are ordered from the longest prefix to the shortest
+	mounts   []mount
+	notFound http.HandlerFunc
+}
+
+// mount is a file system served below the prefix, which ends with '/'
+type mount struct {
+	prefix string
+	fs     *FileSystemWith404
+}
+
+// NewRouter creates a handler serving each file system below its URL path
+// prefix, e.g. "/docs/" and "/blog/", with the longest matching prefix
+// winning. A prefix of "/" serves all other paths. Every file system gets
+// its own FileSystemWith404 created with the options, so caches and the
+// resolution of index pages and hidden files are independent. Paths are
+// served relative to the prefix, "/docs/guide.html" is "/guide.html" of
+// the docs file system. The prefix itself without the trailing '/' is
+// redirected to it. Paths below no prefix are passed to notFound.
+//
+// Prefixes are cleaned and get a trailing '/', so "docs" and "/docs/" are
+// the same prefix. Like http.ServeMux, NewRouter panics when two of them
+// are the same.
+func NewRouter(roots map[string]http.FileSystem, notFound http.HandlerFunc, opts ...Option) *Router {
+	rt := &Router{notFound: notFound}
+	given := make(map[string]string, len(roots))
+	for name, root := range roots {
+		prefix := mountPrefix(name)
+		if other, ok := given[prefix]; ok {
+			if other > name {
+				other, name = name, other
+			}
+			panic(fmt.Sprintf("filesys404: prefixes %q and %q are both mounted at %s", other, name, prefix))
+		}
+		given[prefix] = name
+		rt.mounts = append(rt.mounts, mount{prefix: prefix, fs: New(root, notFound, opts...)})
+	}
+	sort.SliceStable(rt.mounts, func(i, j int) bool {
+		if len(rt.mounts[i].prefix) != len(rt.mounts[j].prefix) {
+			return len(rt.mounts[i].prefix) > len(rt.mounts[j].prefix)
+		}
+		return rt.mounts[i].prefix < rt.mounts[j].prefix
+	})
+	return rt
+}
+
+// mountPrefix returns the cleaned prefix ending with '/'
+func mountPrefix(prefix string) string {
+	prefix = path.Clean("/" + prefix)
+	if prefix != "/" {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// Mount returns the handler of the file system mounted at the prefix, to
+// reach its Stats, Invalidate, SetNotFound or PrecomputeETags. The prefix
+// is cleaned like those of NewRouter. It returns nil when nothing is
+// mounted there.
+func (rt *Router) Mount(prefix string) *FileSystemWith404 {
+	prefix = mountPrefix(prefix)
+	for _, m := range rt.mounts {
+		if m.prefix == prefix {
+			return m.fs
+		}
+	}
+	return nil
+}
+
+// ServeHTTP is the implementation of the Handler interface
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if asteriskOptions(r) && len(rt.mounts) > 0 {
+		// The file systems share the options, any of them can answer
+		rt.mounts[0].fs.ServeHTTP(w, r)
+		return
+	}
+
+	upath := r.URL.Path
+	if !strings.HasPrefix(upath, "/") {
+		upath = "/" + upath
+	}
+	for _, m := range rt.mounts {
+		if upath+"/" == m.prefix {
+			res := redirectTo(r, path.Base(upath)+"/", http.StatusMovedPermanently)
+			localRedirect(w, res.location, res.status)
+			return
+		}
+		if strings.HasPrefix(upath, m.prefix) {
+			m.fs.ServeHTTP(w, rewritePath(r, upath[len(m.prefix)-1:]))
+			return
+		}
+	}
+	if rt.notFound != nil {
+		rt.notFound(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// Close closes the handlers of all file systems
+func (rt *Router) Close() error {
+	for _, m := range rt.mounts {
+		m.fs.Close()
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	rt := NewRouter(map[string]http.FileSystem{
+		"/":              MapFS(map[string]string{"index.html": "site", "docs/old.html": "shadowed"}),
+		"docs":           MapFS(map[string]string{"index.html": "docs", "guide.html": "guide", "api/x.txt": "x"}),
+		"/docs/api/":     MapFS(map[string]string{"index.txt": "api", "x.txt": "api x"}),
+		"/blog/2021/../": MapFS(map[string]string{"index.html": "blog"}),
+	}, testNotFound, WithIndexPages("index.html", "index.txt"))
+	defer rt.Close()
+
+	for _, tc := range []struct {
+		target string
+		code   int
+		body   string
+	}{
+		{"/", http.StatusOK, "site"},
+		{"/docs/", http.StatusOK, "docs"},
+		{"/docs/guide.html", http.StatusOK, "guide"},
+		{"/docs/old.html", http.StatusNotFound, notFoundBody},
+		// The longest prefix wins over index pages of the shorter one
+		{"/docs/api/", http.StatusOK, "api"},
+		{"/docs/api/x.txt", http.StatusOK, "api x"},
+		{"/blog/", http.StatusOK, "blog"},
+		{"/missing", http.StatusNotFound, notFoundBody},
+	} {
+		expect(t, serve(rt, http.MethodGet, tc.target), tc.code, tc.body)
+	}
+
+	// Prefixes without the slash are redirected to it
+	for target, location := range map[string]string{"/docs": "docs/", "/docs/api": "api/"} {
+		w := serve(rt, http.MethodGet, target)
+		expect(t, w, http.StatusMovedPermanently, "")
+		if got := w.Header().Get("Location"); got != location {
+			t.Errorf("%s: Location %q, want %q", target, got, location)
+		}
+	}
+}
+
+func TestRouterWithoutRoot(t *testing.T) {
+	rt := NewRouter(map[string]http.FileSystem{
+		"/docs/": MapFS(map[string]string{"index.html": "docs", ".env": "secret"}),
+	}, testNotFound)
+	expect(t, serve(rt, http.MethodGet, "/docs/"), http.StatusOK, "docs")
+	expect(t, serve(rt, http.MethodGet, "/docs/.env"), http.StatusNotFound, notFoundBody)
+	expect(t, serve(rt, http.MethodGet, "/"), http.StatusNotFound, notFoundBody)
+	expect(t, serve(rt, http.MethodGet, "/docsx/"), http.StatusNotFound, notFoundBody)
+
+	// Without notFound the default 404 page is served
+	rt = NewRouter(map[string]http.FileSystem{"/docs/": MapFS(nil)}, nil)
+	expect(t, serve(rt, http.MethodGet, "/other"), http.StatusNotFound, "404 page not found\n")
+}
+
+func TestRouterDuplicatePrefix(t *testing.T) {
+	defer func() {
+		if err := recover(); err == nil || !strings.Contains(fmt.Sprint(err), `"/docs/" and "docs" are both mounted at /docs/`) {
+			t.Errorf("panic %v, want the duplicate prefixes", err)
+		}
+	}()
+	NewRouter(map[string]http.FileSystem{"docs": MapFS(nil), "/docs/": MapFS(nil)}, testNotFound)
+}
+
+func TestRouterMount(t *testing.T) {
+	rt := NewRouter(map[string]http.FileSystem{
+		"/":     MapFS(map[string]string{"index.html": "site"}),
+		"/docs": MapFS(map[string]string{"index.html": "docs"}),
+	}, testNotFound, WithLatencyHistogram())
+	docs := rt.Mount("docs/")
+	if docs == nil || rt.Mount("/") == nil || rt.Mount("/docs/") != docs {
+		t.Fatalf("mounts %p %p", docs, rt.Mount("/"))
+	}
+	if rt.Mount("/blog/") != nil {
+		t.Errorf("Mount of an unknown prefix is not nil")
+	}
+
+	docs.SetNotFound(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "docs not found")
+	})
+	expect(t, serve(rt, http.MethodGet, "/docs/missing"), http.StatusNotFound, "docs not found")
+	expect(t, serve(rt, http.MethodGet, "/missing"), http.StatusNotFound, notFoundBody)
+
+	var served uint64
+	for _, b := range docs.Stats().Latency {
+		served += b.Count
+	}
+	if served != 1 {
+		t.Errorf("docs served %d requests, want 1", served)
+	}
+}
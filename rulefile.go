@@ -0,0 +1,91 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ruleFile caches the parsed content of a configuration file stored in
+// the root, like a Netlify style "_headers" file. The file is parsed again
+// whenever its ModTime changes. A missing file yields nil rules.
+type ruleFile struct {
+	name  string
+	parse func(r io.Reader) (interface{}, error)
+
+	mu      sync.Mutex
+	loaded  bool
+	modTime time.Time
+	rules   interface{}
+}
+
+// newRuleFile creates a rule file for the named file of the root
+func newRuleFile(name string, parse func(r io.Reader) (interface{}, error)) *ruleFile {
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + name
+	}
+	return &ruleFile{name: name, parse: parse}
+}
+
+// get returns the current rules, parsing the file again if it changed.
+// The previous rules are kept when the changed file fails to parse.
+func (rf *ruleFile) get(ctx context.Context, fs *FileSystemWith404) interface{} {
+	f, d, err := fs.open(ctx, rf.name, nil)
+
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if err != nil {
+		rf.loaded, rf.modTime, rf.rules = true, time.Time{}, nil
+		return nil
+	}
+	defer f.Close()
+	if rf.loaded && d.ModTime().Equal(rf.modTime) {
+		return rf.rules
+	}
+
+	rules, err := rf.parse(f)
+	if err != nil {
+		return rf.rules
+	}
+	rf.loaded, rf.modTime, rf.rules = true, d.ModTime(), rules
+	return rules
+}
+
+// reset makes the next get parse the file again
+func (rf *ruleFile) reset() {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	rf.loaded = false
+}
+
+// splatPattern compiles a Netlify style path pattern. A '*' matches any
+// remaining characters, a ":name" segment matches a single path segment.
+func splatPattern(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i, seg := range strings.Split(pattern, "/") {
+		if i > 0 {
+			b.WriteString("/")
+		}
+		if strings.HasPrefix(seg, ":") && len(seg) > 1 {
+			b.WriteString("([^/]+)")
+			continue
+		}
+		parts := strings.Split(seg, "*")
+		for j, part := range parts {
+			if j > 0 {
+				b.WriteString("(.*)")
+			}
+			b.WriteString(regexp.QuoteMeta(part))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// bufferUnseekable reads the content of a file unable to seek into
+// memory, up to the configured limit. Larger files are returned as a
+// reader continuing with the part already read.
+func (fs *FileSystemWith404) bufferUnseekable(f io.Reader) (io.ReadSeeker, io.Reader, error) {
+	if fs.bufferNonSeekable <= 0 {
+		return nil, f, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(f, int64(fs.bufferNonSeekable)+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) > fs.bufferNonSeekable {
+		return nil, io.MultiReader(bytes.NewReader(data), f), nil
+	}
+	return bytes.NewReader(data), nil, nil
+}
+
+// peekStream reads up to n bytes from the start of the stream, replacing
+// it with one that still starts with them.
+func peekStream(stream *io.Reader, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	l, err := io.ReadFull(*stream, buf)
+	*stream = io.MultiReader(bytes.NewReader(buf[:l]), *stream)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return buf[:l], nil
+}
+
+// streamContent writes the content as is with a 200 OK response, for
+// files that can not seek. There is no Range or conditional request
+// support in that case.
+func streamContent(w http.ResponseWriter, r *http.Request, d os.FileInfo, body io.Reader) {
+	h := w.Header()
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "application/octet-stream")
+	}
+	if d.Size() > 0 {
+		h.Set("Content-Length", strconv.FormatInt(d.Size(), 10))
+	}
+	if !d.ModTime().IsZero() {
+		h.Set("Last-Modified", d.ModTime().UTC().Format(http.TimeFormat))
+	}
+	h.Set("Accept-Ranges", "none")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		io.Copy(w, body)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unseekableFS serves the files of the MapFS unable to seek, like pipes
+// of a FUSE backend. Reads of the blocked file wait for unblock.
+type unseekableFS struct {
+	http.FileSystem
+	blocked string
+	unblock chan struct{}
+}
+
+type unseekableFile struct {
+	http.File
+	unblock chan struct{}
+}
+
+func (u unseekableFS) Open(name string) (http.File, error) {
+	f, err := u.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if d, err := f.Stat(); err == nil && d.IsDir() {
+		return f, nil
+	}
+	file := unseekableFile{File: f}
+	if name == u.blocked {
+		file.unblock = u.unblock
+	}
+	return file, nil
+}
+
+func (f unseekableFile) Seek(int64, int) (int64, error) {
+	return 0, errors.New("seek not supported")
+}
+
+func (f unseekableFile) Read(p []byte) (int, error) {
+	if f.unblock != nil {
+		<-f.unblock
+	}
+	return f.File.Read(p)
+}
+
+func TestUnseekable(t *testing.T) {
+	root := unseekableFS{FileSystem: MapFS(map[string]string{
+		"a.txt":      "streamed",
+		"page.html":  "<script>x()</script>",
+		"data":       "%PDF-1.4 x",
+		"_headers":   "/a.txt\n  X-Rule: yes\n",
+		"index.html": "i",
+	})}
+	h := New(root, testNotFound,
+		WithHeadersFile("/_headers"),
+		WithHeadersByContentType(map[string][]HeaderKV{"text/*": {{"X-Type", "text"}}}),
+		WithCSP("script-src 'self'"), WithCSPNonce(true))
+
+	w := serve(h, "GET", "/a.txt")
+	expect(t, w, 200, "streamed")
+	for name, want := range map[string]string{"Accept-Ranges": "none", "X-Rule": "yes", "X-Type": "text", "Content-Length": "8"} {
+		if got := w.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	w = serve(h, "GET", "/page.html")
+	nonce := w.Header().Get("Content-Security-Policy")
+	if !strings.Contains(nonce, "'nonce-") || !strings.Contains(w.Body.String(), `<script nonce="`) {
+		t.Errorf("page without nonce: %q %q", nonce, w.Body.String())
+	}
+
+	// Types of streams are sniffed like http.ServeContent does
+	if got := serve(h, "GET", "/data").Header().Get("Content-Type"); got != "application/pdf" {
+		t.Errorf("Content-Type = %q, want application/pdf", got)
+	}
+}
+
+func TestUnseekableBuffered(t *testing.T) {
+	root := unseekableFS{FileSystem: MapFS(map[string]string{"a.txt": "0123456789"})}
+	h := New(root, testNotFound, WithBufferNonSeekable(64), WithETag(true))
+	w := serve(h, "GET", "/a.txt", "Range", "bytes=2-4")
+	expect(t, w, 206, "234")
+	if w.Header().Get("ETag") == "" {
+		t.Error("buffered file has no ETag")
+	}
+}
+
+func TestUnseekableLimited(t *testing.T) {
+	root := unseekableFS{
+		FileSystem: MapFS(map[string]string{"slow.txt": "slow", "a.txt": "a"}),
+		blocked:    "/slow.txt",
+		unblock:    make(chan struct{}),
+	}
+	h := New(root, testNotFound, WithMaxConcurrentServes(1, RejectServes), WithBufferNonSeekable(1<<20))
+
+	done := make(chan string)
+	go func() {
+		done <- serve(h, "GET", "/slow.txt").Body.String()
+	}()
+	// The buffering read holds the only slot
+	deadline := time.Now().Add(5 * time.Second)
+	for len(h.limiter.slots) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	expect(t, serve(h, "GET", "/a.txt"), 503, "")
+	close(root.unblock)
+	if body := <-done; body != "slow" {
+		t.Errorf("body = %q, want slow", body)
+	}
+	expect(t, serve(h, "GET", "/a.txt"), 200, "a")
+}
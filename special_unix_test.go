@@ -0,0 +1,48 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+//go:build unix
+
+package filesys404
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSpecialFiles(t *testing.T) {
+	root := t.TempDir()
+	if err := syscall.Mkfifo(filepath.Join(root, "pipe"), 0o644); err != nil {
+		t.Skip("fifos are not supported:", err)
+	}
+	if err := os.Symlink("pipe", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	fs := New(http.Dir(root), testNotFound, WithDirectoryListing(true))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		expect(t, serve(fs, http.MethodGet, "/pipe"), http.StatusNotFound, notFoundBody)
+		expect(t, serve(fs, http.MethodGet, "/link"), http.StatusNotFound, notFoundBody)
+		w := serve(fs, http.MethodGet, "/")
+		if strings.Contains(w.Body.String(), "pipe") || strings.Contains(w.Body.String(), "link") {
+			t.Errorf("listing shows the fifo:\n%s", w.Body.String())
+		}
+		fs.WalkServable(func(upath string, info os.FileInfo) error {
+			t.Errorf("walked %s", upath)
+			return nil
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("serving a fifo blocks")
+	}
+}
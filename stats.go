@@ -0,0 +1,85 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds of the latency histogram
+// buckets used when WithLatencyHistogram is given no bounds.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// Stats is a snapshot of the request statistics of the handler
+type Stats struct {
+	// Latency is the histogram of request serve durations. It is nil
+	// unless enabled using WithLatencyHistogram.
+	Latency []LatencyBucket
+}
+
+// LatencyBucket counts the requests served within its upper bound and
+// above the bound of the previous bucket. The bound of the last bucket
+// is math.MaxInt64 and collects all slower requests.
+type LatencyBucket struct {
+	UpperBound time.Duration
+	Count      uint64
+}
+
+// latencyHistogram counts request durations using atomic counters
+type latencyHistogram struct {
+	bounds []time.Duration
+	counts []uint64
+}
+
+// newLatencyHistogram creates a histogram with the sorted bounds and
+// an additional overflow bucket.
+func newLatencyHistogram(bounds []time.Duration) *latencyHistogram {
+	b := append([]time.Duration(nil), bounds...)
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	b = append(b, time.Duration(math.MaxInt64))
+	return &latencyHistogram{
+		bounds: b,
+		counts: make([]uint64, len(b)),
+	}
+}
+
+// observe records the duration into its bucket
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := sort.Search(len(h.bounds), func(i int) bool { return d <= h.bounds[i] })
+	atomic.AddUint64(&h.counts[i], 1)
+}
+
+// snapshot returns the current bucket counts
+func (h *latencyHistogram) snapshot() []LatencyBucket {
+	buckets := make([]LatencyBucket, len(h.bounds))
+	for i, bound := range h.bounds {
+		buckets[i] = LatencyBucket{
+			UpperBound: bound,
+			Count:      atomic.LoadUint64(&h.counts[i]),
+		}
+	}
+	return buckets
+}
+
+// Stats returns a snapshot of the request statistics
+func (fs *FileSystemWith404) Stats() Stats {
+	var s Stats
+	if fs.latency != nil {
+		s.Latency = fs.latency.snapshot()
+	}
+	return s
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"math"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	fs := New(MapFS(map[string]string{"a.txt": "a"}), testNotFound,
+		WithLatencyHistogram(100*time.Millisecond, time.Millisecond, 10*time.Millisecond))
+	clock := time.Unix(1600000000, 0)
+	var step time.Duration
+	fs.now = func() time.Time {
+		clock = clock.Add(step)
+		return clock
+	}
+
+	for _, step = range []time.Duration{500 * time.Microsecond, time.Millisecond, 5 * time.Millisecond, 50 * time.Millisecond, time.Second} {
+		serve(fs, http.MethodGet, "/a.txt")
+	}
+	step = 2 * time.Millisecond
+	serve(fs, http.MethodGet, "/missing")
+
+	want := []LatencyBucket{
+		{UpperBound: time.Millisecond, Count: 2},
+		{UpperBound: 10 * time.Millisecond, Count: 2},
+		{UpperBound: 100 * time.Millisecond, Count: 1},
+		{UpperBound: time.Duration(math.MaxInt64), Count: 1},
+	}
+	if got := fs.Stats().Latency; !reflect.DeepEqual(got, want) {
+		t.Errorf("Latency = %v, want %v", got, want)
+	}
+}
+
+func TestLatencyHistogramDisabled(t *testing.T) {
+	fs := New(MapFS(map[string]string{"a.txt": "a"}), testNotFound)
+	serve(fs, http.MethodGet, "/a.txt")
+	if got := fs.Stats().Latency; got != nil {
+		t.Errorf("Latency = %v without the option", got)
+	}
+	fs = New(MapFS(nil), testNotFound, WithLatencyHistogram())
+	if got := len(fs.Stats().Latency); got != len(DefaultLatencyBuckets)+1 {
+		t.Errorf("%d default buckets, want %d", got, len(DefaultLatencyBuckets)+1)
+	}
+}
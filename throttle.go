@@ -0,0 +1,78 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// throttleChunks is the number of writes a second of a throttled body is
+// split into, keeping the pace smooth.
+const throttleChunks = 10
+
+// throttledWriter paces the response body to the rate in bytes per
+// second. Writes are split into small chunks, each followed by waiting
+// until its bytes are due, or until the request context is done.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx   context.Context
+	rate  int64
+	start time.Time
+	sent  int64
+}
+
+func newThrottledWriter(ctx context.Context, w http.ResponseWriter, rate int64) *throttledWriter {
+	return &throttledWriter{ResponseWriter: w, ctx: ctx, rate: rate, start: time.Now()}
+}
+
+func (w *throttledWriter) Write(b []byte) (int, error) {
+	chunk := int(w.rate / throttleChunks)
+	if chunk < 1 {
+		chunk = 1
+	}
+
+	written := 0
+	for len(b) > 0 {
+		n := chunk
+		if n > len(b) {
+			n = len(b)
+		}
+		m, err := w.ResponseWriter.Write(b[:n])
+		written += m
+		w.sent += int64(m)
+		if err != nil {
+			return written, err
+		}
+		if err := w.wait(); err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+	return written, nil
+}
+
+// wait blocks until the bytes sent so far are within the rate
+func (w *throttledWriter) wait() error {
+	due := w.start.Add(time.Duration(w.sent * int64(time.Second) / w.rate))
+	d := time.Until(due)
+	if d <= 0 {
+		return w.ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-w.ctx.Done():
+		return w.ctx.Err()
+	}
+}
+
+// Unwrap returns the original ResponseWriter
+func (w *throttledWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
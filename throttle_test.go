@@ -0,0 +1,69 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// throttleRate is the rate of the throttle tests in bytes per second
+const throttleRate = 100000
+
+func timedServe(h http.Handler, target string, headers ...string) (*httptest.ResponseRecorder, time.Duration) {
+	start := time.Now()
+	w := serve(h, http.MethodGet, target, headers...)
+	return w, time.Since(start)
+}
+
+func TestThrottle(t *testing.T) {
+	big := strings.Repeat("x", 3*throttleRate/10)
+	fs := New(MapFS(map[string]string{"big.bin": big}), testNotFound, WithThrottle(throttleRate))
+
+	// 30000 bytes at 100000 bytes per second take about 300ms
+	w, took := timedServe(fs, "/big.bin")
+	expect(t, w, http.StatusOK, big)
+	if took < 250*time.Millisecond || took > 2*time.Second {
+		t.Errorf("throttled body took %v, want about 300ms", took)
+	}
+
+	// Only the bytes of the range are paced
+	w, took = timedServe(fs, "/big.bin", "Range", "bytes=0-9999")
+	expect(t, w, http.StatusPartialContent, big[:10000])
+	if took < 80*time.Millisecond || took > time.Second {
+		t.Errorf("throttled range took %v, want about 100ms", took)
+	}
+
+	// Error responses are not throttled
+	w, took = timedServe(fs, "/missing")
+	expect(t, w, http.StatusNotFound, notFoundBody)
+	if took > 100*time.Millisecond {
+		t.Errorf("404 took %v, it must not be throttled", took)
+	}
+}
+
+func TestThrottleCancel(t *testing.T) {
+	big := strings.Repeat("x", 10*throttleRate)
+	fs := New(MapFS(map[string]string{"big.bin": big}), testNotFound, WithThrottle(throttleRate))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	r := httptest.NewRequest(http.MethodGet, "/big.bin", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	// The 10s body stops soon after the request is gone
+	start := time.Now()
+	fs.ServeHTTP(w, r)
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("canceled request took %v", took)
+	}
+	if w.Body.Len() >= len(big) {
+		t.Errorf("canceled request got the whole body")
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serverTiming collects the Server-Timing metrics of a single request.
+// A nil *serverTiming is valid and records nothing.
+type serverTiming struct {
+	now     func() time.Time
+	header  bool
+	names   []string
+	metrics map[string]time.Duration
+	descs   map[string]string
+}
+
+// newTiming returns a collector when Server-Timing, tracing or the cache
+// status header is enabled, else nil. The header is only sent with
+// Server-Timing enabled.
+func (fs *FileSystemWith404) newTiming() *serverTiming {
+	if !fs.serverTiming && fs.tracer == nil && fs.cacheStatusHeader == "" {
+		return nil
+	}
+	return &serverTiming{
+		now:     fs.now,
+		header:  fs.serverTiming,
+		metrics: make(map[string]time.Duration),
+		descs:   make(map[string]string),
+	}
+}
+
+// start returns the time a measurement begins
+func (t *serverTiming) start() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.now()
+}
+
+// measure adds the time elapsed since start to the named metric
+func (t *serverTiming) measure(name string, start time.Time) {
+	if t == nil {
+		return
+	}
+	t.add(name)
+	t.metrics[name] += t.now().Sub(start)
+}
+
+// describe sets the description of the named metric, e.g. a cache hit
+func (t *serverTiming) describe(name, desc string) {
+	if t == nil {
+		return
+	}
+	t.add(name)
+	t.descs[name] = desc
+}
+
+// add registers the metric name keeping the order of first use
+func (t *serverTiming) add(name string) {
+	_, measured := t.metrics[name]
+	_, described := t.descs[name]
+	if !measured && !described {
+		t.names = append(t.names, name)
+	}
+}
+
+// String formats the metrics as a Server-Timing header value
+func (t *serverTiming) String() string {
+	entries := make([]string, 0, len(t.names))
+	for _, name := range t.names {
+		entry := name
+		if desc, ok := t.descs[name]; ok {
+			entry += ";desc=" + strconv.Quote(desc)
+		}
+		if d, ok := t.metrics[name]; ok {
+			ms := float64(d) / float64(time.Millisecond)
+			entry += ";dur=" + strconv.FormatFloat(ms, 'f', 3, 64)
+		}
+		entries = append(entries, entry)
+	}
+	return strings.Join(entries, ", ")
+}
+
+// wrap returns a ResponseWriter that adds the Server-Timing header
+// just before the response headers are written.
+func (t *serverTiming) wrap(w http.ResponseWriter) http.ResponseWriter {
+	if t == nil || !t.header {
+		return w
+	}
+	return onWriteHeader(w, func(h http.Header, _ int) {
+		if len(t.names) > 0 {
+			h.Add("Server-Timing", t.String())
+		}
+	})
+}
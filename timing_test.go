@@ -0,0 +1,59 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serverTimingEntry matches one metric of a Server-Timing header
+var serverTimingEntry = regexp.MustCompile(`^[a-z]+(;desc="[^"]*")?(;dur=[0-9]+\.[0-9]{3})?$`)
+
+func TestServerTiming(t *testing.T) {
+	fs := New(MapFS(map[string]string{"a.txt": "a"}), testNotFound, WithServerTiming(true), WithETag(true))
+	clock := time.Unix(1600000000, 0)
+	fs.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	w := serve(fs, http.MethodGet, "/a.txt")
+	expect(t, w, http.StatusOK, "a")
+	header := w.Header().Get("Server-Timing")
+	names := make(map[string]bool)
+	for _, entry := range strings.Split(header, ", ") {
+		if !serverTimingEntry.MatchString(entry) {
+			t.Errorf("malformed entry %q of %q", entry, header)
+		}
+		names[strings.SplitN(entry, ";", 2)[0]] = true
+	}
+	for _, name := range []string{"open", "stat", "cache", "etag"} {
+		if !names[name] {
+			t.Errorf("Server-Timing %q misses %s", header, name)
+		}
+	}
+	if !strings.Contains(header, `cache;desc="miss"`) {
+		t.Errorf("cold request Server-Timing %q, want a cache miss", header)
+	}
+	if !strings.Contains(header, "open;dur=1.000") {
+		t.Errorf("Server-Timing %q, want open taking 1ms of the clock", header)
+	}
+
+	header = serve(fs, http.MethodGet, "/a.txt").Header().Get("Server-Timing")
+	if !strings.Contains(header, `cache;desc="hit"`) {
+		t.Errorf("warm request Server-Timing %q, want a cache hit", header)
+	}
+}
+
+func TestServerTimingDisabled(t *testing.T) {
+	fs := New(MapFS(map[string]string{"a.txt": "a"}), testNotFound)
+	if header := serve(fs, http.MethodGet, "/a.txt").Header().Get("Server-Timing"); header != "" {
+		t.Errorf("Server-Timing = %q without the option", header)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Tracer starts spans for distributed tracing. It is shaped after the
+// OpenTelemetry trace.Tracer, which a few lines of adapter turn into one,
+// so the package does not depend on it.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a traced operation started by a Tracer
+type Span interface {
+	// SetAttribute records the value, a string, int, bool or float64
+	SetAttribute(key string, value interface{})
+	// RecordError records the error the operation failed with
+	RecordError(err error)
+	// End completes the span
+	End()
+}
+
+// Attribute keys of the spans started for requests
+const (
+	// TraceResolution is the decision taken, like "file" or "redirect"
+	TraceResolution = "filesys404.resolution"
+	// TracePath is the resolved file, or the redirect location
+	TracePath = "filesys404.path"
+	// TraceStatus is the status code of the response
+	TraceStatus = "http.status_code"
+	// TraceCache is "hit" or "miss" when the ETag cache was consulted
+	TraceCache = "filesys404.cache"
+	// TraceDuration is the time serving the request took in milliseconds
+	TraceDuration = "filesys404.duration_ms"
+)
+
+// resolutionNames are the TraceResolution values of the decisions
+var resolutionNames = map[resolutionKind]string{
+	resolveNotFound: "not_found",
+	resolveHidden:   "hidden",
+	resolveFile:     "file",
+	resolveFallback: "fallback",
+	resolveRedirect: "redirect",
+	resolveError:    "error",
+	resolveListing:  "listing",
+	resolveRobots:   "robots",
+	resolveRoot:     "root",
+	resolveManifest: "manifest",
+}
+
+// requestTrace collects the span attributes of a single request.
+// A nil *requestTrace is valid and records nothing.
+type requestTrace struct {
+	span  Span
+	start time.Time
+	code  int
+	err   error
+
+	// kind and path describe the resolution once known
+	hasRes bool
+	kind   resolutionKind
+	path   string
+}
+
+// startTrace starts the span of the request when a tracer is configured.
+// The returned request carries the span context.
+func (fs *FileSystemWith404) startTrace(w http.ResponseWriter, r *http.Request) (*requestTrace, http.ResponseWriter, *http.Request) {
+	if fs.tracer == nil {
+		return nil, w, r
+	}
+	ctx, span := fs.tracer.Start(r.Context(), "filesys404.ServeHTTP")
+	t := &requestTrace{span: span, start: fs.now(), code: http.StatusOK}
+	w = onWriteHeader(w, func(_ http.Header, code int) {
+		t.code = code
+	})
+	return t, w, r.WithContext(ctx)
+}
+
+// resolved records the resolution of the request
+func (t *requestTrace) resolved(res *resolution, err error) {
+	if t == nil {
+		return
+	}
+	t.err = err
+	// The fields are copied, keeping the resolution off the heap
+	t.hasRes, t.kind, t.path = true, res.kind, res.name
+	if res.kind == resolveRedirect {
+		t.path = res.location
+	}
+}
+
+// end sets the attributes of the span and ends it
+func (t *requestTrace) end(fs *FileSystemWith404, st *serverTiming) {
+	if t == nil {
+		return
+	}
+	if t.hasRes {
+		t.span.SetAttribute(TraceResolution, resolutionNames[t.kind])
+		if t.path != "" {
+			t.span.SetAttribute(TracePath, t.path)
+		}
+	}
+	if desc, ok := st.descs["cache"]; ok {
+		t.span.SetAttribute(TraceCache, desc)
+	}
+	t.span.SetAttribute(TraceStatus, t.code)
+	t.span.SetAttribute(TraceDuration, float64(fs.now().Sub(t.start))/float64(time.Millisecond))
+	if t.err != nil {
+		t.span.RecordError(t.err)
+	}
+	t.span.End()
+}
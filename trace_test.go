@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// spanKey is the context key the recording tracer marks span contexts with
+type spanKey struct{}
+
+// recordingTracer keeps every span it starts
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	name  string
+	attrs map[string]interface{}
+	errs  []error
+	ended bool
+}
+
+func (tr *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	s := &recordedSpan{name: name, attrs: make(map[string]interface{})}
+	tr.spans = append(tr.spans, s)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// last returns the span started last
+func (tr *recordingTracer) last() *recordedSpan {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return tr.spans[len(tr.spans)-1]
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *recordedSpan) RecordError(err error) {
+	s.errs = append(s.errs, err)
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &recordingTracer{}
+	fetcher := errorFetcher{
+		files: FileSystemFetcher(MapFS(map[string]string{"a.txt": "a", "docs/index.html": "docs", "broken/a.txt": "a"})),
+		errs:  map[string]error{"/broken/index.html": errors.New("storage unreachable")},
+	}
+	var notFoundSpan interface{}
+	fs := NewFetcher(fetcher, func(w http.ResponseWriter, r *http.Request) {
+		notFoundSpan = r.Context().Value(spanKey{})
+		testNotFound(w, r)
+	}, WithTracer(tracer), WithETag(true))
+	clock := time.Unix(1600000000, 0)
+	fs.now = func() time.Time {
+		clock = clock.Add(2 * time.Millisecond)
+		return clock
+	}
+
+	for _, tc := range []struct {
+		target string
+		attrs  map[string]interface{}
+		err    bool
+	}{
+		{"/a.txt", map[string]interface{}{
+			TraceResolution: "file",
+			TracePath:       "/a.txt",
+			TraceStatus:     http.StatusOK,
+			TraceCache:      "miss",
+		}, false},
+		{"/a.txt", map[string]interface{}{
+			TraceResolution: "file",
+			TracePath:       "/a.txt",
+			TraceStatus:     http.StatusOK,
+			TraceCache:      "hit",
+		}, false},
+		{"/docs", map[string]interface{}{
+			TraceResolution: "redirect",
+			TracePath:       "docs/",
+			TraceStatus:     http.StatusMovedPermanently,
+		}, false},
+		{"/missing", map[string]interface{}{
+			TraceResolution: "not_found",
+			TraceStatus:     http.StatusNotFound,
+		}, false},
+		{"/broken/", map[string]interface{}{
+			TraceStatus: http.StatusInternalServerError,
+		}, true},
+	} {
+		serve(fs, http.MethodGet, tc.target)
+		s := tracer.last()
+		if s.name != "filesys404.ServeHTTP" || !s.ended {
+			t.Errorf("%s: span %q ended %v", tc.target, s.name, s.ended)
+		}
+		for key, want := range tc.attrs {
+			if got := s.attrs[key]; got != want {
+				t.Errorf("%s: %s = %v, want %v", tc.target, key, got, want)
+			}
+		}
+		for _, key := range []string{TracePath, TraceCache} {
+			if _, ok := tc.attrs[key]; !ok && s.attrs[key] != nil {
+				t.Errorf("%s: unexpected %s = %v", tc.target, key, s.attrs[key])
+			}
+		}
+		if d, ok := s.attrs[TraceDuration].(float64); !ok || d <= 0 {
+			t.Errorf("%s: %s = %v, want a positive float64", tc.target, TraceDuration, s.attrs[TraceDuration])
+		}
+		if got := len(s.errs) > 0; got != tc.err {
+			t.Errorf("%s: recorded errors %v", tc.target, s.errs)
+		}
+	}
+	if notFoundSpan == nil {
+		t.Errorf("notFound handler not called with the span context")
+	}
+	if len(tracer.spans) != 5 {
+		t.Errorf("%d spans, want 5", len(tracer.spans))
+	}
+}
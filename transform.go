@@ -0,0 +1,100 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxTransformCache is the largest transformed content kept in memory
+const maxTransformCache = 256 << 10
+
+// transformEntry is the cached transformed content of a file
+type transformEntry struct {
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+// transformCache stores transformed content of files by name. An entry
+// is only valid while the ModTime and size of the file stay the same.
+type transformCache struct {
+	mu      sync.Mutex
+	entries map[string]transformEntry
+}
+
+// get returns the cached content of the file if it is still valid
+func (c *transformCache) get(name string, modTime time.Time, size int64) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[name]
+	if !ok || !e.modTime.Equal(modTime) || e.size != size {
+		return nil, false
+	}
+	return e.data, true
+}
+
+// put stores the transformed content of the file
+func (c *transformCache) put(name string, modTime time.Time, size int64, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]transformEntry)
+	}
+	c.entries[name] = transformEntry{modTime: modTime, size: size, data: data}
+}
+
+// delete drops the content of the file
+func (c *transformCache) delete(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, name)
+}
+
+// clear drops all content
+func (c *transformCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// transformContent returns the content of the file after the transform.
+// Results up to maxTransformCache are kept for the following requests,
+// fresh transforms ignore the kept result and replace it.
+func (fs *FileSystemWith404) transformContent(name string, d os.FileInfo, content io.ReadSeeker, fresh bool, st *serverTiming) (io.ReadSeeker, error) {
+	if data, ok := fs.transforms.get(name, d.ModTime(), d.Size()); ok && !fresh {
+		st.describe("transform", "hit")
+		return bytes.NewReader(data), nil
+	}
+
+	start := st.start()
+	rs, err := fs.transform(name, content)
+	st.measure("transform", start)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if size > maxTransformCache {
+		return rs, nil
+	}
+
+	data, err := io.ReadAll(rs)
+	if err != nil {
+		return nil, err
+	}
+	fs.transforms.put(name, d.ModTime(), d.Size(), data)
+	return bytes.NewReader(data), nil
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReadSeekerTransform(t *testing.T) {
+	calls := 0
+	fs := New(MapFS(map[string]string{
+		"config.json": `{"env": "{{ENV}}"}`,
+		"broken.json": "{}",
+		"a.txt":       "{{ENV}}",
+	}), testNotFound, WithReadSeekerTransform(func(name string, rs io.ReadSeeker) (io.ReadSeeker, error) {
+		calls++
+		switch name {
+		case "/broken.json":
+			return nil, errors.New("transform failed")
+		case "/config.json":
+			data, err := io.ReadAll(rs)
+			if err != nil {
+				return nil, err
+			}
+			return bytes.NewReader(bytes.ReplaceAll(data, []byte("{{ENV}}"), []byte("production"))), nil
+		}
+		return rs, nil
+	}))
+
+	want := `{"env": "production"}`
+	w := serve(fs, http.MethodGet, "/config.json")
+	expect(t, w, http.StatusOK, want)
+	if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(want)) {
+		t.Errorf("Content-Length = %s, want %d", got, len(want))
+	}
+
+	// Ranges apply to the transformed content
+	w = serve(fs, http.MethodGet, "/config.json", "Range", "bytes=9-18")
+	expect(t, w, http.StatusPartialContent, "production")
+	if got := w.Header().Get("Content-Range"); got != "bytes 9-18/"+strconv.Itoa(len(want)) {
+		t.Errorf("Content-Range = %q", got)
+	}
+	if calls != 1 {
+		t.Errorf("transformed %d times, want the result cached", calls)
+	}
+
+	expect(t, serve(fs, http.MethodGet, "/a.txt"), http.StatusOK, "{{ENV}}")
+	w = serve(fs, http.MethodGet, "/broken.json")
+	expect(t, w, http.StatusInternalServerError, "")
+	if strings.Contains(w.Body.String(), "transform failed") {
+		t.Errorf("error leaked: %q", w.Body.String())
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// resolveTryFiles resolves the request path like the try_files directive
+// of nginx. All but the last pattern are tried in order, the first one
+// naming an existing file, or a directory with an index page for patterns
+// ending in '/', is served. The last pattern is the fallback, either a
+// "=code" status or the path of a file to serve.
+func (fs *FileSystemWith404) resolveTryFiles(r *http.Request, urlPath string, st *serverTiming) (resolution, error) {
+	ctx := r.Context()
+	last := len(fs.tryFiles) - 1
+	for _, pattern := range fs.tryFiles[:last] {
+		candidate := strings.Replace(pattern, "$uri", urlPath, -1)
+		if !strings.HasPrefix(candidate, "/") {
+			candidate = "/" + candidate
+		}
+		if fs.hidden(ctx, candidate) {
+			continue
+		}
+
+		name := path.Clean(candidate)
+		if strings.HasSuffix(candidate, "/") {
+			if name, f, d, err := fs.openIndex(ctx, name, st); err == nil {
+				return fs.resolveFile(r, name, f, d, st)
+			}
+			continue
+		}
+
+		f, d, err := fs.open(ctx, name, st)
+		if err != nil {
+			continue
+		}
+		if d.IsDir() {
+			f.Close()
+			continue
+		}
+		return fs.resolveFile(r, name, f, d, st)
+	}
+
+	fallback := strings.Replace(fs.tryFiles[last], "$uri", urlPath, -1)
+	if strings.HasPrefix(fallback, "=") {
+		code, err := strconv.Atoi(fallback[1:])
+		if err != nil || code < 400 || code > 599 {
+			code = http.StatusNotFound
+		}
+		return resolution{kind: resolveError, status: code}, nil
+	}
+
+	name := path.Clean("/" + fallback)
+	f, d, err := fs.open(ctx, name, st)
+	if err != nil {
+		return resolution{kind: resolveNotFound}, ctx.Err()
+	}
+	if d.IsDir() {
+		f.Close()
+		return resolution{kind: resolveNotFound}, nil
+	}
+	return fs.resolveFile(r, name, f, d, st)
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"testing"
+)
+
+// tryResult is the expected response of a request
+type tryResult struct {
+	code int
+	body string
+}
+
+func TestTryFiles(t *testing.T) {
+	files := map[string]string{
+		"index.html":      "spa",
+		"about.html":      "about",
+		"docs/index.html": "docs",
+		"app.js":          "js",
+		".env":            "secret",
+		".fallback.html":  "hidden fallback",
+	}
+	for _, c := range []struct {
+		name     string
+		patterns []string
+		results  map[string]tryResult
+	}{
+		{"SPA", []string{"$uri", "$uri/", "/index.html"}, map[string]tryResult{
+			"/app.js":   {http.StatusOK, "js"},
+			"/docs":     {http.StatusOK, "docs"},
+			"/users/42": {http.StatusOK, "spa"},
+			"/about":    {http.StatusOK, "spa"},
+			"/.env":     {http.StatusNotFound, notFoundBody},
+		}},
+		{"extensionless", []string{"$uri", "$uri.html", "$uri/", "=404"}, map[string]tryResult{
+			"/about":      {http.StatusOK, "about"},
+			"/about.html": {http.StatusOK, "about"},
+			"/docs":       {http.StatusOK, "docs"},
+			"/missing":    {http.StatusNotFound, notFoundBody},
+		}},
+		{"status", []string{"$uri", "=503"}, map[string]tryResult{
+			"/app.js":  {http.StatusOK, "js"},
+			"/missing": {http.StatusServiceUnavailable, "Service Unavailable\n"},
+		}},
+		{"hidden fallback", []string{"$uri", "/.fallback.html"}, map[string]tryResult{
+			"/missing": {http.StatusOK, "hidden fallback"},
+		}},
+		{"missing fallback", []string{"$uri", "/gone.html"}, map[string]tryResult{
+			"/missing": {http.StatusNotFound, notFoundBody},
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			fs := New(MapFS(files), testNotFound, WithTryFiles(c.patterns...))
+			for target, want := range c.results {
+				w := serve(fs, http.MethodGet, target)
+				if w.Code != want.code || w.Body.String() != want.body {
+					t.Errorf("%s: %d %q, want %d %q", target, w.Code, w.Body.String(), want.code, want.body)
+				}
+			}
+		})
+	}
+}
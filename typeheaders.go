@@ -0,0 +1,71 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// HeaderKV is a response header of WithHeadersByContentType
+type HeaderKV struct {
+	Key   string
+	Value string
+}
+
+// newTypeHeaders returns the headers by lower cased media type
+func newTypeHeaders(headers map[string][]HeaderKV) map[string][]HeaderKV {
+	byType := make(map[string][]HeaderKV, len(headers))
+	for pattern, kvs := range headers {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		byType[pattern] = append(byType[pattern], kvs...)
+	}
+	return byType
+}
+
+// applyTypeHeaders sets the headers of the media type of the content
+// type. The headers of the exact type "text/html" are applied before those
+// of "text/*", then those of "*/*". Headers already set, by an earlier
+// pattern or by other options, are kept. Vary values are added to the
+// ones already set instead.
+func applyTypeHeaders(h http.Header, byType map[string][]HeaderKV, ctype string) {
+	mediaType, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return
+	}
+	patterns := []string{mediaType, "*/*"}
+	if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+		patterns = []string{mediaType, mediaType[:i] + "/*", "*/*"}
+	}
+
+	preset := make(map[string]bool)
+	for key := range h {
+		preset[key] = true
+	}
+	for _, pattern := range patterns {
+		set := make(map[string]bool)
+		for _, kv := range byType[pattern] {
+			key := http.CanonicalHeaderKey(kv.Key)
+			if key == "Vary" {
+				addVary(h, kv.Value)
+				continue
+			}
+			if preset[key] {
+				continue
+			}
+			// Repeated keys of a pattern add several values
+			if set[key] {
+				h.Add(key, kv.Value)
+			} else {
+				h.Set(key, kv.Value)
+				set[key] = true
+			}
+		}
+		for key := range set {
+			preset[key] = true
+		}
+	}
+}
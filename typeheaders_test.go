@@ -0,0 +1,48 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHeadersByContentType(t *testing.T) {
+	files := map[string]string{
+		"_headers": "/*\n  Vary: Cookie\n  X-Frame-Options: DENY\n",
+		"page":     "<!DOCTYPE html><html><body>sniffed</body></html>",
+		"logo.png": "\x89PNG\r\n\x1a\n",
+	}
+	fs := New(MapFS(files), testNotFound, WithHeadersFile("/_headers"), WithHeadersByContentType(map[string][]HeaderKV{
+		"text/html": {{"Cache-Control", "no-cache"}, {"Vary", "Origin"}},
+		"text/*":    {{"Cache-Control", "public"}, {"X-Text", "yes"}},
+		"*/*":       {{"Vary", "Accept"}, {"X-Frame-Options", "SAMEORIGIN"}},
+		"image/*":   {{"Cache-Control", "public, max-age=31536000"}},
+	}))
+
+	w := serve(fs, http.MethodGet, "/page")
+	expect(t, w, http.StatusOK, "")
+	h := w.Header()
+	for key, want := range map[string]string{
+		"Cache-Control":   "no-cache",
+		"X-Text":          "yes",
+		"X-Frame-Options": "DENY",
+		"Vary":            "Cookie, Origin, Accept",
+	} {
+		if got := strings.Join(h.Values(key), ", "); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	w = serve(fs, http.MethodGet, "/logo.png")
+	expect(t, w, http.StatusOK, "")
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=31536000" {
+		t.Errorf("image Cache-Control = %q", got)
+	}
+	if got := w.Header().Get("X-Text"); got != "" {
+		t.Errorf("image X-Text = %q", got)
+	}
+}
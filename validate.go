@@ -0,0 +1,135 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NewStrict creates a new FileSystem404 instance like New, but returns an
+// error when options contradict each other or have no effect, instead of
+// silently serving with them. It reports:
+//
+//   - WithSPAFallback or WithSPABase used together with WithTryFiles,
+//     whose fallback pattern replaces the SPA fallback
+//   - WithSPABase without WithSPAFallback
+//   - WithCSPNonce without a policy from WithCSP or WithCSPReportOnly
+//   - WithCanonicalURLPolicy together with WithRedirectIndexToDir,
+//     WithServeIndexWithoutRedirect or WithTryFiles
+//   - WithImmutablePrefix for the root "/", caching HTML pages forever
+//   - WithEarlyHints without WithPreloadLinks
+//   - WithListingTemplate without WithDirectoryListing or WithListingMatcher
+//   - WithDirectoryListing together with WithListingMatcher, which
+//     replaces it
+//   - malformed patterns of WithDenyGlobs, WithAllowGlobs and
+//     WithNotFoundRoutes
+//   - a try_files fallback "=code" that is no 4xx or 5xx status
+//   - error pages for codes that are no 4xx or 5xx status
+//   - negative sizes and rates
+//   - a WithCompressionLevel outside of 1 to 9
+//   - the same file for WithHeadersFile and WithRedirectsFile
+//   - aliases of WithAliases redirecting in a cycle, or prefix aliases
+//     redirecting below themselves
+func NewStrict(r http.FileSystem, notFound http.HandlerFunc, opts ...Option) (*FileSystemWith404, error) {
+	fs := New(r, notFound, opts...)
+	if problems := fs.validate(); len(problems) > 0 {
+		return nil, fmt.Errorf("filesys404: invalid configuration: %s", strings.Join(problems, "; "))
+	}
+	return fs, nil
+}
+
+// validate returns the problems of the configuration
+func (fs *FileSystemWith404) validate() []string {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(fs.tryFiles) > 0 && (fs.spaFallback != "" || fs.spaBase != "") {
+		report("WithSPAFallback has no effect with WithTryFiles, use a fallback pattern instead")
+	}
+	if fs.spaBase != "" && fs.spaFallback == "" {
+		report("WithSPABase needs WithSPAFallback")
+	}
+	if fs.cspNonce && fs.csp == "" && fs.cspReportOnly == "" {
+		report("WithCSPNonce needs a policy using WithCSP or WithCSPReportOnly")
+	}
+	if fs.canonicalPolicy != PolicyNone && (fs.redirectIndex || fs.indexWithoutRedirect) {
+		report("WithCanonicalURLPolicy replaces WithRedirectIndexToDir and WithServeIndexWithoutRedirect")
+	}
+	if fs.canonicalPolicy != PolicyNone && len(fs.tryFiles) > 0 {
+		report("WithCanonicalURLPolicy has no effect on paths resolved by WithTryFiles")
+	}
+	if fs.immutablePrefix == "/" {
+		report("WithImmutablePrefix for the root caches HTML pages forever")
+	}
+	if fs.earlyHints && fs.preloadLinks == nil {
+		report("WithEarlyHints needs WithPreloadLinks")
+	}
+	if fs.listingTemplate != nil && !fs.listing && fs.listingMatcher == nil {
+		report("WithListingTemplate needs WithDirectoryListing or WithListingMatcher")
+	}
+	if fs.listing && fs.listingMatcher != nil {
+		report("WithDirectoryListing has no effect with WithListingMatcher")
+	}
+
+	patterns := append(append([]string(nil), fs.denyGlobs...), fs.allowGlobs...)
+	for _, route := range fs.notFoundRoutes {
+		patterns = append(patterns, route.pattern)
+	}
+	for _, pattern := range patterns {
+		for _, segment := range splitSegments(pattern) {
+			if _, err := path.Match(segment, ""); err != nil {
+				report("glob %q: %v", pattern, err)
+				break
+			}
+		}
+	}
+	if n := len(fs.tryFiles); n > 0 && strings.HasPrefix(fs.tryFiles[n-1], "=") {
+		if code, err := strconv.Atoi(fs.tryFiles[n-1][1:]); err != nil || code < 400 || code > 599 {
+			report("try_files fallback %q is no error status", fs.tryFiles[n-1])
+		}
+	}
+	var codes []int
+	for code := range fs.errorPages {
+		if code < 400 || code > 599 {
+			codes = append(codes, code)
+		}
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		report("error page for status %d which is no error", code)
+	}
+
+	if fs.throttle < 0 {
+		report("WithThrottle rate %d is negative", fs.throttle)
+	}
+	if fs.notFoundDelay < 0 || fs.notFoundJitter < 0 {
+		report("WithNotFoundDelay and WithNotFoundJitter durations must not be negative")
+	}
+	if fs.bufferTransformed < 0 {
+		report("WithBufferTransformed size %d is negative", fs.bufferTransformed)
+	}
+	if fs.gzipLevel != 0 && compressionLevel(fs.gzipLevel) != fs.gzipLevel {
+		report("WithCompressionLevel %d is not between 1 and 9", fs.gzipLevel)
+	}
+	if fs.headersFile != nil && fs.redirectsFile != nil && fs.headersFile.name == fs.redirectsFile.name {
+		report("WithHeadersFile and WithRedirectsFile use the same file %s", fs.headersFile.name)
+	}
+	if fs.aliases != nil {
+		for _, from := range fs.aliases.nested {
+			report("prefix alias %s redirects below itself", from)
+		}
+		for _, from := range fs.aliases.cycles() {
+			report("alias %s redirects in a cycle", from)
+		}
+	}
+	return problems
+}
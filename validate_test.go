@@ -0,0 +1,82 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewStrict(t *testing.T) {
+	files := MapFS(map[string]string{"index.html": "home"})
+	for _, tc := range []struct {
+		opts []Option
+		want string
+	}{
+		{[]Option{WithSPAFallback("/index.html"), WithTryFiles("$uri", "/index.html")}, "WithSPAFallback has no effect with WithTryFiles"},
+		{[]Option{WithSPABase("/app/")}, "WithSPABase needs WithSPAFallback"},
+		{[]Option{WithCSPNonce(true)}, "WithCSPNonce needs a policy"},
+		{[]Option{WithCanonicalURLPolicy(PolicyTrailingSlash), WithRedirectIndexToDir(true)}, "WithCanonicalURLPolicy replaces WithRedirectIndexToDir"},
+		{[]Option{WithCanonicalURLPolicy(PolicyExtensionless), WithTryFiles("$uri", "=404")}, "no effect on paths resolved by WithTryFiles"},
+		{[]Option{WithImmutablePrefix("/", false)}, "caches HTML pages forever"},
+		{[]Option{WithEarlyHints(true)}, "WithEarlyHints needs WithPreloadLinks"},
+		{[]Option{WithListingTemplate(template.Must(template.New("l").Parse("")))}, "WithListingTemplate needs WithDirectoryListing"},
+		{[]Option{WithDirectoryListing(true), WithListingMatcher(func(string) bool { return true })}, "WithDirectoryListing has no effect with WithListingMatcher"},
+		{[]Option{WithDenyGlobs("/[a-")}, `glob "/[a-"`},
+		{[]Option{WithTryFiles("$uri", "=200")}, `try_files fallback "=200" is no error status`},
+		{[]Option{WithErrorPages(map[int]string{http.StatusOK: "/index.html"})}, "error page for status 200 which is no error"},
+		{[]Option{WithThrottle(-1)}, "WithThrottle rate -1 is negative"},
+		{[]Option{WithNotFoundDelay(-time.Second)}, "durations must not be negative"},
+		{[]Option{WithCompressionLevel(12)}, "WithCompressionLevel 12 is not between 1 and 9"},
+		{[]Option{WithHeadersFile("/_config"), WithRedirectsFile("/_config")}, "use the same file /_config"},
+		{[]Option{WithAliases(map[string]string{"/a": "/b", "/b": "/a"})}, "redirects in a cycle"},
+		{[]Option{WithAliases(map[string]string{"/docs/": "/docs/v2/"})}, "prefix alias /docs/ redirects below itself"},
+	} {
+		fs, err := NewStrict(files, testNotFound, tc.opts...)
+		if err == nil {
+			t.Errorf("%q: no error", tc.want)
+			continue
+		}
+		if fs != nil {
+			t.Errorf("%q: file system returned with the error", tc.want)
+		}
+		if !strings.HasPrefix(err.Error(), "filesys404: invalid configuration: ") || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("error %q, want it to report %q", err, tc.want)
+		}
+	}
+}
+
+func TestNewStrictValid(t *testing.T) {
+	fs, err := NewStrict(MapFS(map[string]string{"index.html": "home"}), testNotFound,
+		WithSPAFallback("/index.html"),
+		WithCSP("default-src 'self'"), WithCSPNonce(true),
+		WithImmutablePrefix("/assets/", true),
+		WithDenyGlobs("/**/*.map"),
+		WithErrorPages(map[int]string{http.StatusNotFound: "/index.html"}),
+		WithCompressionLevel(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect(t, serve(fs, http.MethodGet, "/"), http.StatusOK, "")
+}
+
+func TestNewStrictProblems(t *testing.T) {
+	// Every problem is reported at once
+	_, err := NewStrict(MapFS(nil), testNotFound, WithThrottle(-1), WithCSPNonce(true), WithSPABase("/app/"))
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, want := range []string{"WithThrottle", "WithCSPNonce", "WithSPABase"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q misses %s", err, want)
+		}
+	}
+	if n := strings.Count(err.Error(), "; "); n != 2 {
+		t.Errorf("error %q has %d problems, want 3", err, n+1)
+	}
+}
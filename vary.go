@@ -0,0 +1,40 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"strings"
+)
+
+// addVary adds the request header names to the Vary header of the
+// response. All the values are kept in a single header without
+// duplicates, a "*" value replacing all others.
+func addVary(h http.Header, names ...string) {
+	var values []string
+	seen := make(map[string]bool)
+	for _, v := range append(h.Values("Vary"), names...) {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if name == "*" {
+				h.Set("Vary", "*")
+				return
+			}
+			key := http.CanonicalHeaderKey(name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			values = append(values, key)
+		}
+	}
+	if len(values) == 0 {
+		return
+	}
+	h.Set("Vary", strings.Join(values, ", "))
+}
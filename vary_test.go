@@ -0,0 +1,50 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAddVary(t *testing.T) {
+	for _, c := range []struct {
+		preset []string
+		names  []string
+		want   []string
+	}{
+		{nil, []string{"accept-encoding"}, []string{"Accept-Encoding"}},
+		{[]string{"Origin"}, []string{"Accept-Encoding", "origin"}, []string{"Origin, Accept-Encoding"}},
+		{[]string{"Origin, Cookie", "Accept"}, []string{"cookie"}, []string{"Origin, Cookie, Accept"}},
+		{[]string{"Origin"}, []string{"*"}, []string{"*"}},
+		{[]string{"*"}, []string{"Origin"}, []string{"*"}},
+		{nil, []string{" , "}, nil},
+	} {
+		h := http.Header{"Vary": c.preset}
+		addVary(h, c.names...)
+		if got := h.Values("Vary"); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("addVary(%q, %q) = %q, want %q", c.preset, c.names, got, c.want)
+		}
+	}
+}
+
+func TestVaryAggregated(t *testing.T) {
+	page := strings.Repeat("<p>bonjour</p>", 100)
+	fs := New(MapFS(map[string]string{
+		"page.html":    "default",
+		"page.fr.html": page,
+		"_headers":     "/*\n  Vary: Cookie\n",
+	}), testNotFound, WithLanguageNegotiation(true), WithGzip(true), WithHeadersFile("/_headers"),
+		WithHeadersByContentType(map[string][]HeaderKV{"text/html": {{"Vary", "Origin, accept-language"}}}))
+
+	w := serve(fs, http.MethodGet, "/page.html", "Accept-Language", "fr", "Accept-Encoding", "gzip")
+	expect(t, w, http.StatusOK, "")
+	want := []string{"Accept-Language, Cookie, Origin, Accept-Encoding"}
+	if got := w.Header().Values("Vary"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Vary = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"context"
+	"os"
+	"path"
+	"sort"
+)
+
+// WalkServable calls fn for every path the handler would serve a file for,
+// applying the same filters as ServeHTTP. Directories having an index
+// page are reported with a trailing '/' and the file info of the index,
+// other directories are not reported. Paths are visited in lexical order.
+// An error returned by fn stops the walk and is returned. It is safe to
+// call while requests are being served, e.g. to warm caches or to
+// validate a deployment.
+func (fs *FileSystemWith404) WalkServable(fn func(upath string, info os.FileInfo) error) error {
+	return fs.walkDir(context.Background(), "/", nil, fn)
+}
+
+// walkDir walks the directory with the cleaned path dir below the
+// ancestors, skipping a directory linked back to one of them
+func (fs *FileSystemWith404) walkDir(ctx context.Context, dir string, ancestors []os.FileInfo, fn func(upath string, info os.FileInfo) error) error {
+	f, info, err := fs.open(ctx, dir, nil)
+	if err != nil {
+		return err
+	}
+	if revisited(ancestors, info) {
+		f.Close()
+		return nil
+	}
+	ancestors = append(ancestors, info)
+	entries, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	// The directory URL is filtered like a request for it is
+	if !fs.globDenied(dir) && !(fs.rejectReserved && reservedPath(dir)) {
+		if _, idx, d, err := fs.openIndex(ctx, dir, nil); err == nil {
+			idx.Close()
+			dirPath := dir
+			if dirPath != "/" {
+				dirPath += "/"
+			}
+			if err := fn(dirPath, d); err != nil {
+				return err
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	for _, d := range entries {
+		upath := path.Join(dir, d.Name())
+		if fs.hidden(ctx, upath) || (fs.rejectReserved && reservedSegment(d.Name())) {
+			continue
+		}
+		d, ok := fs.entryInfo(ctx, upath, d)
+		if !ok {
+			continue
+		}
+		if d.IsDir() {
+			if err := fs.walkDir(ctx, upath, ancestors[:len(ancestors):len(ancestors)], fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if !fs.servable(upath, d) || fs.globDenied(upath) {
+			continue
+		}
+		if err := fn(upath, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
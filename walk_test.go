@@ -0,0 +1,156 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// symlinkTree creates a directory with a linked file, a linked directory
+// and a link back to the root
+func symlinkTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range map[string]string{"a.txt": "a", "sub/b.txt": "b"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{"link.txt": "a.txt", "linkdir": "sub", "sub/loop": "..", "broken": "missing"} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Skip("symlinks are not supported:", err)
+		}
+	}
+	return root
+}
+
+func TestWalkServableSymlinks(t *testing.T) {
+	fs := New(http.Dir(symlinkTree(t)), testNotFound)
+	var got []string
+	err := fs.WalkServable(func(upath string, info os.FileInfo) error {
+		got = append(got, upath)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/a.txt", "/link.txt", "/linkdir/b.txt", "/sub/b.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("walked %q, want %q", got, want)
+	}
+	expect(t, serve(fs, http.MethodGet, "/link.txt"), http.StatusOK, "a")
+	expect(t, serve(fs, http.MethodGet, "/linkdir/b.txt"), http.StatusOK, "b")
+	expect(t, serve(fs, http.MethodGet, "/broken"), http.StatusNotFound, notFoundBody)
+}
+
+func TestListingSymlinks(t *testing.T) {
+	fs := New(http.Dir(symlinkTree(t)), testNotFound, WithDirectoryListing(true))
+	w := serve(fs, http.MethodGet, "/")
+	expect(t, w, http.StatusOK, "")
+	body := w.Body.String()
+	for _, name := range []string{"link.txt", "linkdir/"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("listing misses %s:\n%s", name, body)
+		}
+	}
+	if strings.Contains(body, "broken") {
+		t.Errorf("listing shows the broken link:\n%s", body)
+	}
+}
+
+func TestLintSymlinks(t *testing.T) {
+	root := symlinkTree(t)
+	if err := os.WriteFile(filepath.Join(root, "sub", "empty.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, w := range New(http.Dir(root), testNotFound).Lint() {
+		if w.Category == LintEmptyFile {
+			got = append(got, w.Path)
+		}
+	}
+	want := []string{"/linkdir/empty.txt", "/sub/empty.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("empty file warnings %q, want %q", got, want)
+	}
+}
+
+func TestWalkServableDenied(t *testing.T) {
+	files := map[string]string{
+		"index.html":        "root",
+		"public/index.html": "public",
+		"secret/index.html": "secret",
+		"secret/a.txt":      "a",
+	}
+	fs := New(MapFS(files), testNotFound, WithDenyGlobs("/secret/**"))
+	var got []string
+	err := fs.WalkServable(func(upath string, info os.FileInfo) error {
+		got = append(got, upath)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/", "/index.html", "/public/", "/public/index.html"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("walked %q, want %q", got, want)
+	}
+	for _, upath := range want {
+		expect(t, serve(fs, http.MethodGet, upath), http.StatusOK, "")
+	}
+	expect(t, serve(fs, http.MethodGet, "/secret/"), http.StatusNotFound, notFoundBody)
+}
+
+func TestWalkServableMatchesServe(t *testing.T) {
+	files := map[string]string{
+		"index.html":          "home",
+		"a.txt":               "a",
+		"empty.txt":           "",
+		".env":                "secret",
+		"_headers":            "/*\n  X-A: b\n",
+		"docs/index.html":     "docs",
+		"docs/guide.md":       "guide",
+		"docs/app.js.map":     "map",
+		"noindex/b.txt":       "b",
+		".git/config":         "secret",
+		"assets/.hidden/x.js": "x",
+	}
+	fs := New(MapFS(files), testNotFound, WithRejectEmptyFiles(true), WithHeadersFile("/_headers"), WithDenyGlobs("/**/*.map"))
+
+	walked := make(map[string]bool)
+	err := fs.WalkServable(func(upath string, info os.FileInfo) error {
+		walked[upath] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for upath := range walked {
+		expect(t, serve(fs, http.MethodGet, upath), http.StatusOK, "")
+	}
+	candidates := []string{"/", "/docs/", "/noindex/", "/.git/", "/assets/"}
+	for name := range files {
+		candidates = append(candidates, "/"+name)
+	}
+	for _, upath := range candidates {
+		if walked[upath] {
+			continue
+		}
+		if w := serve(fs, http.MethodGet, upath); w.Code == http.StatusOK {
+			t.Errorf("%s is served but not walked", upath)
+		}
+	}
+	if len(walked) != 7 {
+		t.Errorf("walked %v, want 7 paths", walked)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"net/http"
+)
+
+// headerHook is a ResponseWriter calling a function just before the
+// response headers are written, allowing final changes to them.
+type headerHook struct {
+	http.ResponseWriter
+	before      func(h http.Header, code int)
+	wroteHeader bool
+}
+
+// onWriteHeader wraps the ResponseWriter to call before with the
+// response headers and status code just before they are written.
+func onWriteHeader(w http.ResponseWriter, before func(h http.Header, code int)) http.ResponseWriter {
+	return &headerHook{ResponseWriter: w, before: before}
+}
+
+func (w *headerHook) WriteHeader(code int) {
+	if !w.wroteHeader && !informational(code) {
+		w.wroteHeader = true
+		w.before(w.Header(), code)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *headerHook) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the original ResponseWriter
+func (w *headerHook) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"io"
+	"net/http"
+)
+
+// zeroCopyWriter passes the body read by ReadFrom to the ReaderFrom of
+// the server's ResponseWriter, which sends files using sendfile(2) on
+// supported platforms instead of copying them through user space.
+type zeroCopyWriter struct {
+	http.ResponseWriter
+	dst io.ReaderFrom
+}
+
+// zeroCopy returns the ResponseWriter copying bodies with the ReaderFrom
+// wrapped by w. Only the header hooks are looked through, as they have
+// run once the body is written. Writers changing the body like gzip or
+// throttling keep the standard copy, as does a server ResponseWriter
+// without ReaderFrom.
+func zeroCopy(w http.ResponseWriter) http.ResponseWriter {
+	inner := w
+	for {
+		hook, ok := inner.(*headerHook)
+		if !ok {
+			break
+		}
+		inner = hook.ResponseWriter
+	}
+	if inner == w {
+		// Already used by the standard copy
+		return w
+	}
+	dst, ok := inner.(io.ReaderFrom)
+	if !ok {
+		return w
+	}
+	return &zeroCopyWriter{ResponseWriter: w, dst: dst}
+}
+
+func (w *zeroCopyWriter) ReadFrom(src io.Reader) (int64, error) {
+	// Run the header hooks in case nothing was written yet
+	w.ResponseWriter.Write(nil)
+	return w.dst.ReadFrom(src)
+}
+
+// Unwrap returns the original ResponseWriter
+func (w *zeroCopyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2021 Abhijit Bose. All Right reserved.
+// Use of this source code is governed by a Apache 2.0 license that can be found
+// in the LICENSE file.
+
+package filesys404
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readerFromRecorder records if the body was copied using ReadFrom
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (w *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(w.ResponseRecorder, src)
+}
+
+func TestZeroCopyThreshold(t *testing.T) {
+	big, small := strings.Repeat("b", 4096), "small"
+	files := MapFS(map[string]string{"big.txt": big, "small.txt": small})
+	for _, tc := range []struct {
+		name     string
+		opts     []Option
+		target   string
+		headers  []string
+		body     string
+		readFrom bool
+	}{
+		{"above", []Option{WithServerTiming(true)}, "/big.txt", nil, big, true},
+		{"range", []Option{WithServerTiming(true)}, "/big.txt", []string{"Range", "bytes=0-99"}, big[:100], true},
+		{"below", []Option{WithServerTiming(true)}, "/small.txt", nil, small, false},
+		{"gzip", []Option{WithServerTiming(true), WithGzip(true)}, "/big.txt", []string{"Accept-Encoding", "gzip"}, "", false},
+		{"throttle", []Option{WithServerTiming(true), WithThrottle(1 << 30)}, "/big.txt", nil, big, false},
+	} {
+		fs := New(files, testNotFound, append(tc.opts, WithZeroCopyThreshold(1024))...)
+		r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		for i := 0; i+1 < len(tc.headers); i += 2 {
+			r.Header.Set(tc.headers[i], tc.headers[i+1])
+		}
+		w := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+		fs.ServeHTTP(w, r)
+		if tc.body != "" {
+			if got := w.Body.String(); got != tc.body {
+				t.Errorf("%s: body of %d bytes, want %d", tc.name, len(got), len(tc.body))
+			}
+		}
+		if w.readFrom != tc.readFrom {
+			t.Errorf("%s: ReadFrom used %v, want %v", tc.name, w.readFrom, tc.readFrom)
+		}
+		if tc.name != "gzip" && w.Header().Get("Server-Timing") == "" {
+			t.Errorf("%s: header hooks did not run", tc.name)
+		}
+	}
+}
+
+func BenchmarkZeroCopy(b *testing.B) {
+	dir := b.TempDir()
+	const size = 16 << 20
+	if err := os.WriteFile(filepath.Join(dir, "big.bin"), make([]byte, size), 0644); err != nil {
+		b.Fatal(err)
+	}
+	for _, bc := range []struct {
+		name      string
+		threshold int64
+	}{
+		{"copy", 0},
+		{"zero-copy", 1 << 20},
+	} {
+		// Server-Timing wraps the ResponseWriter, hiding its ReaderFrom
+		// from the standard copy
+		srv := httptest.NewServer(New(http.Dir(dir), testNotFound, WithServerTiming(true), WithZeroCopyThreshold(bc.threshold)))
+		b.Run(bc.name, func(b *testing.B) {
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				res, err := http.Get(srv.URL + "/big.bin")
+				if err != nil {
+					b.Fatal(err)
+				}
+				n, _ := io.Copy(io.Discard, res.Body)
+				res.Body.Close()
+				if n != size {
+					b.Fatalf("read %d bytes, want %d", n, size)
+				}
+			}
+		})
+		srv.Close()
+	}
+}